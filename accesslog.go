@@ -0,0 +1,178 @@
+package mngr
+
+import (
+	"bytes"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// CommonLogFormat is the Common Log Format of Apache.
+	CommonLogFormat = `%h %l %u %t "%r" %>s %b`
+	// CombinedLogFormat is the Combined Log Format of Apache, the common
+	// format plus the referer and the user agent.
+	CombinedLogFormat = CommonLogFormat + ` "%{Referer}i" "%{User-Agent}i"`
+)
+
+// sizeWriter is an http.ResponseWriter which counts the bytes of the body.
+type sizeWriter struct {
+	http.ResponseWriter
+	size int64
+}
+
+func (w *sizeWriter) Write(b []byte) (int, error) {
+	n, err := w.ResponseWriter.Write(b)
+	w.size += int64(n)
+	return n, err
+}
+
+// Unwrap return the wrapped http.ResponseWriter, for http.ResponseController.
+func (w *sizeWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// logEntry describe a served request, for the access log.
+type logEntry struct {
+	r *http.Request
+	// remote is the address of the client.
+	remote  string
+	header  http.Header
+	start   time.Time
+	elapsed time.Duration
+	code    int
+	size    int64
+}
+
+// logDirective append a field of e to b.
+type logDirective func(b *bytes.Buffer, e *logEntry)
+
+// orDash return s, or "-" when it is empty.
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
+
+// simpleDirectives are the directives of the Apache log formats supported
+// by LogFormat, except the headers.
+var simpleDirectives = map[string]logDirective{
+	"h": func(b *bytes.Buffer, e *logEntry) {
+		host, _, err := net.SplitHostPort(e.remote)
+		if err != nil {
+			host = e.remote
+		}
+		b.WriteString(host)
+	},
+	"l": func(b *bytes.Buffer, e *logEntry) { b.WriteByte('-') },
+	"u": func(b *bytes.Buffer, e *logEntry) {
+		user, _, _ := e.r.BasicAuth()
+		b.WriteString(orDash(user))
+	},
+	"t": func(b *bytes.Buffer, e *logEntry) {
+		b.WriteString(e.start.Format("[02/Jan/2006:15:04:05 -0700]"))
+	},
+	"r": func(b *bytes.Buffer, e *logEntry) {
+		b.WriteString(e.r.Method + " " + e.r.URL.RequestURI() + " " + e.r.Proto)
+	},
+	"s":  func(b *bytes.Buffer, e *logEntry) { b.WriteString(strconv.Itoa(e.code)) },
+	">s": func(b *bytes.Buffer, e *logEntry) { b.WriteString(strconv.Itoa(e.code)) },
+	"b": func(b *bytes.Buffer, e *logEntry) {
+		if e.size == 0 {
+			b.WriteByte('-')
+			return
+		}
+		b.WriteString(strconv.FormatInt(e.size, 10))
+	},
+	"B": func(b *bytes.Buffer, e *logEntry) { b.WriteString(strconv.FormatInt(e.size, 10)) },
+	"D": func(b *bytes.Buffer, e *logEntry) { b.WriteString(strconv.FormatInt(e.elapsed.Microseconds(), 10)) },
+	"T": func(b *bytes.Buffer, e *logEntry) { b.WriteString(strconv.FormatInt(int64(e.elapsed/time.Second), 10)) },
+	"m": func(b *bytes.Buffer, e *logEntry) { b.WriteString(e.r.Method) },
+	"U": func(b *bytes.Buffer, e *logEntry) { b.WriteString(e.r.URL.EscapedPath()) },
+	"q": func(b *bytes.Buffer, e *logEntry) {
+		if e.r.URL.RawQuery != "" {
+			b.WriteString("?" + e.r.URL.RawQuery)
+		}
+	},
+	"H": func(b *bytes.Buffer, e *logEntry) { b.WriteString(e.r.Proto) },
+}
+
+// parseLogFormat compile an Apache log format. Unknown directives are
+// written as is.
+func parseLogFormat(format string) []logDirective {
+	var directives []logDirective
+	literal := func(s string) {
+		directives = append(directives, func(b *bytes.Buffer, e *logEntry) { b.WriteString(s) })
+	}
+	for {
+		i := strings.IndexByte(format, '%')
+		if i < 0 || i == len(format)-1 {
+			if format != "" {
+				literal(format)
+			}
+			return directives
+		}
+		if i > 0 {
+			literal(format[:i])
+		}
+		rest := format[i+1:]
+		switch {
+		case rest[0] == '%':
+			literal("%")
+			format = rest[1:]
+		case rest[0] == '{':
+			j := strings.IndexByte(rest, '}')
+			if j < 0 || j == len(rest)-1 {
+				literal(format[i:])
+				return directives
+			}
+			name, kind := rest[1:j], rest[j+1]
+			switch kind {
+			case 'i':
+				directives = append(directives, func(b *bytes.Buffer, e *logEntry) { b.WriteString(orDash(e.r.Header.Get(name))) })
+			case 'o':
+				directives = append(directives, func(b *bytes.Buffer, e *logEntry) { b.WriteString(orDash(e.header.Get(name))) })
+			default:
+				literal(format[i : i+j+3])
+			}
+			format = rest[j+2:]
+		default:
+			n := 1
+			if rest[0] == '>' && len(rest) > 1 {
+				n = 2
+			}
+			if d, ok := simpleDirectives[rest[:n]]; ok {
+				directives = append(directives, d)
+			} else {
+				literal("%" + rest[:n])
+			}
+			format = rest[n:]
+		}
+	}
+}
+
+// LogFormat make the log middleware write a line per request following
+// format, an Apache log format like CommonLogFormat or CombinedLogFormat.
+// It supports the directives %h, %l, %u, %t, %r, %s, %>s, %b, %B, %D, %T,
+// %m, %U, %q, %H and the request and response headers, %{Name}i and
+// %{Name}o; %{X-Request-ID}o logs the request ID. The user is the one of
+// the HTTP Basic authentication.
+func LogFormat(format string) LogOption {
+	directives := parseLogFormat(format)
+	return func(c *logConfig) {
+		c.format = directives
+	}
+}
+
+// writeFormat write the line of e in the format of c.
+func (c *logConfig) writeFormat(e *logEntry) {
+	var b bytes.Buffer
+	for _, d := range c.format {
+		d(&b, e)
+	}
+	b.WriteByte('\n')
+	c.out.Write(b.Bytes())
+}
@@ -0,0 +1,268 @@
+package mngr
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path"
+	"strings"
+)
+
+// Role is the level of access of an user to a folder. Each role includes
+// the permissions of the lower ones.
+type Role int
+
+const (
+	// RoleNone deny any access.
+	RoleNone Role = iota
+	// RoleViewer allow to read the pages.
+	RoleViewer
+	// RoleEditor allow to modify the pages.
+	RoleEditor
+	// RoleAdmin allow to manage the wiki, like purging the trash.
+	RoleAdmin
+)
+
+var roleNames = []string{"none", "viewer", "editor", "admin"}
+
+func (r Role) String() string {
+	if r < 0 || int(r) >= len(roleNames) {
+		return fmt.Sprintf("Role(%d)", int(r))
+	}
+	return roleNames[r]
+}
+
+// ParseRole return the Role named s.
+func ParseRole(s string) (Role, error) {
+	for i, name := range roleNames {
+		if s == name {
+			return Role(i), nil
+		}
+	}
+	return RoleNone, fmt.Errorf("unknown role %q", s)
+}
+
+// AnyUser is the user of the grants applying to everyone, including the
+// anonymous users.
+const AnyUser = "*"
+
+// Grant give Role to User on the folder Prefix and everything below it.
+type Grant struct {
+	User   string
+	Prefix string
+	Role   Role
+}
+
+// ACL holds the roles granted to the users of the wiki.
+type ACL struct {
+	grants []Grant
+}
+
+// NewACL create an ACL from grants.
+func NewACL(grants ...Grant) *ACL {
+	a := &ACL{grants: make([]Grant, 0, len(grants))}
+	for _, g := range grants {
+		g.Prefix = strings.Trim(path.Clean("/"+g.Prefix), "/")
+		a.grants = append(a.grants, g)
+	}
+	return a
+}
+
+// ParseACL read grants written one per line as "user prefix role", like
+// "alice docs editor". Blank lines and lines starting with # are ignored.
+func ParseACL(r io.Reader) (*ACL, error) {
+	var grants []Grant
+	scanner := bufio.NewScanner(r)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || line[0] == '#' {
+			continue
+		}
+		fields := strings.Fields(line)
+		if len(fields) != 3 {
+			return nil, fmt.Errorf("acl: line %d: expected user, prefix and role", n)
+		}
+		role, err := ParseRole(fields[2])
+		if err != nil {
+			return nil, fmt.Errorf("acl: line %d: %v", n, err)
+		}
+		grants = append(grants, Grant{User: fields[0], Prefix: fields[1], Role: role})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return NewACL(grants...), nil
+}
+
+// LoadACL read the ACL file located at name.
+func LoadACL(name string) (*ACL, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return ParseACL(f)
+}
+
+// inPrefix report whether the path p is the folder prefix or is below it.
+func inPrefix(p, prefix string) bool {
+	return prefix == "" || p == prefix || strings.HasPrefix(p, prefix+"/")
+}
+
+// Role return the role of user on the path p. The grant with the longest
+// prefix containing p wins, a grant to user winning over a grant to AnyUser.
+// Without grant the role is RoleNone.
+func (a *ACL) Role(user, p string) Role {
+	p = strings.Trim(path.Clean("/"+p), "/")
+	role, best, specific := RoleNone, -1, false
+	for _, g := range a.grants {
+		if (g.User != user && g.User != AnyUser) || !inPrefix(p, g.Prefix) {
+			continue
+		}
+		mine := g.User == user
+		if len(g.Prefix) > best || (len(g.Prefix) == best && mine && !specific) {
+			role, best, specific = g.Role, len(g.Prefix), mine
+		}
+	}
+	return role
+}
+
+type aclCtxKey int
+
+var aclKey = aclCtxKey(0)
+
+// ACLFromCtx extract the ACL added to a context by MakeACLMiddleware, for
+// the handlers to check the paths they reach themselves.
+func ACLFromCtx(ctx context.Context) (*ACL, bool) {
+	a, ok := ctx.Value(aclKey).(*ACL)
+	return a, ok
+}
+
+// readFilter return a function reporting whether the user of ctx can read
+// a path with the ACL of ctx, or nil when ctx holds no ACL.
+func readFilter(ctx context.Context) func(p string) bool {
+	a, ok := ACLFromCtx(ctx)
+	if !ok {
+		return nil
+	}
+	user, _ := UserFromCtx(ctx)
+	return func(p string) bool {
+		return a.Role(user, p) >= RoleViewer
+	}
+}
+
+// userCacheKey return key, made specific to the user of ctx when ctx holds
+// an ACL, for the values computed with readFilter.
+func userCacheKey(ctx context.Context, key string) string {
+	if _, ok := ACLFromCtx(ctx); !ok {
+		return key
+	}
+	user, _ := UserFromCtx(ctx)
+	return key + "\x00" + user
+}
+
+// actionRoles is the role required by the actions of a ValidURL. The other
+// actions require RoleViewer for safe methods and RoleEditor otherwise.
+var actionRoles = map[string]Role{
+	"edit":    RoleEditor,
+	"save":    RoleEditor,
+	"new":     RoleEditor,
+	"folder":  RoleEditor,
+	"move":    RoleEditor,
+	"copy":    RoleEditor,
+	"delete":  RoleEditor,
+	"upload":  RoleEditor,
+	"revert":  RoleEditor,
+	"convert": RoleEditor,
+	"touch":   RoleAdmin,
+	"trash":   RoleAdmin,
+}
+
+// safeMethods don't modify the wiki.
+var safeMethods = map[string]bool{
+	http.MethodGet:     true,
+	http.MethodHead:    true,
+	http.MethodOptions: true,
+	"PROPFIND":         true,
+}
+
+// requiredRole return the role needed for the request r, on the paths it
+// targets. Requests without ValidURL target the root folder, their action
+// is the first element of the URL path.
+func requiredRole(r *http.Request) (Role, []string) {
+	valid, ok := ValidURLFromCtx(r.Context())
+	if !ok {
+		action := strings.SplitN(strings.TrimPrefix(r.URL.Path, "/"), "/", 2)[0]
+		valid = ValidURL{Action: action}
+	}
+	role, ok := actionRoles[valid.Action]
+	if !ok {
+		role = RoleEditor
+		if safeMethods[r.Method] {
+			role = RoleViewer
+		}
+	}
+	paths := []string{PagePathFromValidURL(valid)}
+	switch valid.Action {
+	case "new":
+		// The form of the new action names its folder, the API its URL.
+		if valid.Dir == "" {
+			paths[0] = r.FormValue("path")
+		}
+	case "move", "copy":
+		if to := r.FormValue("to"); to != "" {
+			paths = append(paths, to)
+		}
+	}
+	return role, paths
+}
+
+// MakeACLMiddleware create a middleware answering 403 to the requests the
+// user in their context, if any, isn't allowed to make by a. It must be
+// plugged after the middleware adding the ValidURL. The actions modifying
+// pages require RoleEditor on their path, and on the target of move and
+// copy; reading requires RoleViewer. The handlers listing pages of several
+// folders, like the search, skip those the user can't read, a is added to
+// the request's context for them, see ACLFromCtx. Anonymous requests are
+// answered with 401 and the challenge of MakeOptionalBasicAuthMiddleware,
+// when plugged, instead of 403.
+func MakeACLMiddleware(a *ACL) Middleware {
+	return func(h Handler) Handler {
+		return HandlerFunc(func(w http.ResponseWriter, r *http.Request) (int, error) {
+			user, known := UserFromCtx(r.Context())
+			role, paths := requiredRole(r)
+			for _, p := range paths {
+				if a.Role(user, p) < role {
+					if challenge := challengeFromCtx(r.Context()); !known && challenge != "" {
+						w.Header().Set("WWW-Authenticate", challenge)
+						w.Header().Set("Content-Type", "text/plain")
+						w.WriteHeader(http.StatusUnauthorized)
+						w.Write([]byte("unauthorized"))
+						return http.StatusUnauthorized, nil
+					}
+					w.Header().Set("Content-Type", "text/plain")
+					w.WriteHeader(http.StatusForbidden)
+					fmt.Fprintf(w, "forbidden: %s role required on /%s\n", role, strings.Trim(p, "/"))
+					return http.StatusForbidden, nil
+				}
+			}
+			ctx := context.WithValue(r.Context(), aclKey, a)
+			return h.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// withACL create a middleware adding a to the request's context without
+// checking anything, for the handlers checking the role of each path
+// themselves, like WebDAV.
+func withACL(a *ACL) Middleware {
+	return func(h Handler) Handler {
+		return HandlerFunc(func(w http.ResponseWriter, r *http.Request) (int, error) {
+			ctx := context.WithValue(r.Context(), aclKey, a)
+			return h.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
@@ -0,0 +1,79 @@
+package mngr
+
+import (
+	"html"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// SlugOptions configure how heading texts are turned into anchors.
+type SlugOptions struct {
+	// Lowercase the heading text.
+	Lowercase bool
+	// StripPunctuation remove every character which is neither a letter,
+	// a digit, a space, a dash nor an underscore.
+	StripPunctuation bool
+}
+
+// DefaultSlugOptions lowercase headings and strip their punctuation:
+// "Hello, World!" becomes "hello-world".
+var DefaultSlugOptions = SlugOptions{Lowercase: true, StripPunctuation: true}
+
+var (
+	htmlHeading = regexp.MustCompile(`(?s)<h([1-6])(\s[^>]*)?>(.*?)</h[1-6]>`)
+	htmlTag     = regexp.MustCompile(`<[^>]*>`)
+	htmlID      = regexp.MustCompile(`\sid="([^"]*)"`)
+)
+
+// Slugify turn a text into an anchor usable in URLs: spaces become dashes
+// after applying opts. The function is deterministic.
+func Slugify(text string, opts SlugOptions) string {
+	if opts.Lowercase {
+		text = strings.ToLower(text)
+	}
+	var b strings.Builder
+	dash := false
+	for _, r := range strings.TrimSpace(text) {
+		switch {
+		case unicode.IsSpace(r) || r == '-':
+			dash = b.Len() > 0
+			continue
+		case opts.StripPunctuation && !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '_':
+			continue
+		}
+		if dash {
+			b.WriteByte('-')
+			dash = false
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// addHeadingAnchors give an id to every heading of a rendered page which
+// doesn't have one, derived from the heading text with Slugify. Duplicated
+// anchors get a numeric suffix: "intro", "intro-1", "intro-2".
+func addHeadingAnchors(page string, opts SlugOptions) string {
+	used := make(map[string]bool)
+	for _, m := range htmlID.FindAllStringSubmatch(page, -1) {
+		used[m[1]] = true
+	}
+	return htmlHeading.ReplaceAllStringFunc(page, func(h string) string {
+		m := htmlHeading.FindStringSubmatch(h)
+		if htmlID.MatchString(m[2]) {
+			return h
+		}
+		slug := Slugify(html.UnescapeString(htmlTag.ReplaceAllString(m[3], "")), opts)
+		if slug == "" {
+			slug = "section"
+		}
+		id := slug
+		for i := 1; used[id]; i++ {
+			id = slug + "-" + strconv.Itoa(i)
+		}
+		used[id] = true
+		return "<h" + m[1] + m[2] + ` id="` + html.EscapeString(id) + `">` + m[3] + "</h" + m[1] + ">"
+	})
+}
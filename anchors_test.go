@@ -0,0 +1,37 @@
+package mngr
+
+import "testing"
+
+func TestSlugify(t *testing.T) {
+	tests := []struct {
+		text string
+		opts SlugOptions
+		want string
+	}{
+		{"Hello, World!", DefaultSlugOptions, "hello-world"},
+		{"  Install -- on Linux ", DefaultSlugOptions, "install-on-linux"},
+		{"Hello, World!", SlugOptions{}, "Hello,-World!"},
+		{"snake_case", DefaultSlugOptions, "snake_case"},
+	}
+	for _, tt := range tests {
+		if got := Slugify(tt.text, tt.opts); got != tt.want {
+			t.Errorf("Slugify(%q, %+v) = %q, want %q", tt.text, tt.opts, got, tt.want)
+		}
+	}
+}
+
+func TestAddHeadingAnchorsDuplicates(t *testing.T) {
+	page := "<h1>Intro</h1><p>a</p><h2>Intro</h2><p>b</p><h2>Intro</h2><h3 id=\"kept\">Intro</h3>"
+	want := `<h1 id="intro">Intro</h1><p>a</p><h2 id="intro-1">Intro</h2><p>b</p><h2 id="intro-2">Intro</h2><h3 id="kept">Intro</h3>`
+	if got := addHeadingAnchors(page, DefaultSlugOptions); got != want {
+		t.Errorf("addHeadingAnchors(%q) =\n%s\nwant\n%s", page, got, want)
+	}
+}
+
+func TestAddHeadingAnchorsExistingID(t *testing.T) {
+	page := `<h2 id="intro">Custom</h2><h2>Intro</h2><h2><em>!!</em></h2>`
+	want := `<h2 id="intro">Custom</h2><h2 id="intro-1">Intro</h2><h2 id="section"><em>!!</em></h2>`
+	if got := addHeadingAnchors(page, DefaultSlugOptions); got != want {
+		t.Errorf("addHeadingAnchors(%q) =\n%s\nwant\n%s", page, got, want)
+	}
+}
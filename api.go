@@ -0,0 +1,254 @@
+package mngr
+
+import (
+	"encoding/json"
+	"net/http"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// APIError is the body of the API responses reporting an error.
+type APIError struct {
+	Error string `json:"error"`
+}
+
+// APIFile is a file of an APIFolder.
+type APIFile struct {
+	Name  string `json:"name"`
+	Title string `json:"title"`
+}
+
+// APIFolder is the content of a folder returned by the API.
+type APIFolder struct {
+	Path    string    `json:"path"`
+	Folders []string  `json:"folders"`
+	Files   []APIFile `json:"files"`
+}
+
+// APIPage is a page returned by the API. The body of binary files is left
+// out, they can be fetched with the download action.
+type APIPage struct {
+	Path   string `json:"path"`
+	Title  string `json:"title"`
+	Body   string `json:"body,omitempty"`
+	Binary bool   `json:"binary"`
+}
+
+// apiPath match the URLs of the API: /api/v1/{action}/{path}.
+var apiPath = regexp.MustCompile("^/api/v1/([a-z]+)/([a-zA-Z0-9/.]*)$")
+
+// apiRoute describe an action of the API.
+type apiRoute struct {
+	method string
+	// folder is set when the path of the action is a folder.
+	folder  bool
+	handler HandlerFunc
+}
+
+var apiRoutes = map[string]apiRoute{
+	"list":   {method: http.MethodGet, folder: true, handler: apiList},
+	"view":   {method: http.MethodGet, handler: apiView},
+	"save":   {method: http.MethodPut, handler: apiSave},
+	"new":    {method: http.MethodPost, folder: true, handler: apiNew},
+	"folder": {method: http.MethodPost, handler: apiFolder},
+}
+
+// writeAPIError write err as a JSON APIError, with the status code given by
+// DefaultErrorStatus.
+func writeAPIError(w http.ResponseWriter, err error) (int, error) {
+	code := DefaultErrorStatus(err)
+	if code == 0 {
+		code = http.StatusInternalServerError
+	}
+	_, werr := writeJSON(w, code, APIError{Error: err.Error()})
+	if werr != nil {
+		return code, werr
+	}
+	return code, err
+}
+
+// writeAPIStatus write a JSON APIError with code and the status text.
+func writeAPIStatus(w http.ResponseWriter, code int, msg string) (int, error) {
+	return writeJSON(w, code, APIError{Error: strings.ToLower(http.StatusText(code)) + ": " + msg})
+}
+
+// MakeAPIHandler return an handler exposing the pages of the Store added
+// by MakeStoreMiddleware as a JSON API, served under /api/v1/:
+//
+//	GET  /api/v1/list/{folder}  list a folder, as an APIFolder
+//	GET  /api/v1/view/{path}    return a page, as an APIPage
+//	PUT  /api/v1/save/{path}    write {"body": ...} to a page
+//	POST /api/v1/new/{folder}   create {"name": ..., "folder": bool}
+//	POST /api/v1/folder/{path}  create a folder
+//
+// Errors are reported with an APIError. Every handler is wrapped with
+// guard when it isn't nil, like MakeACLMiddleware checking the path of the
+// request, and the handlers modifying the store with write too, to refresh
+// indexes for example; a ValidURL describing the request is then in their
+// context.
+func MakeAPIHandler(guard, write Middleware) HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) (int, error) {
+		m := apiPath.FindStringSubmatch(r.URL.Path)
+		if m == nil {
+			return writeAPIStatus(w, http.StatusNotFound, "unknown action")
+		}
+		route, ok := apiRoutes[m[1]]
+		if !ok {
+			return writeAPIStatus(w, http.StatusNotFound, "unknown action "+m[1])
+		}
+		if r.Method != route.method {
+			w.Header().Set("Allow", route.method)
+			return writeAPIStatus(w, http.StatusMethodNotAllowed, "use "+route.method)
+		}
+		name := strings.Trim(m[2], "/")
+		var valid ValidURL
+		switch {
+		case route.folder && name == "":
+			valid = ValidURL{Action: m[1]}
+		case route.folder && validPagePath.MatchString(name):
+			valid = ValidURL{Action: m[1], Dir: name + "/"}
+		case !route.folder && validPagePath.MatchString(name):
+			file, folder := findFolder(name)
+			valid = ValidURL{Action: m[1], Value: file, Dir: folder}
+		default:
+			return writeAPIStatus(w, http.StatusBadRequest, "invalid path")
+		}
+		var h Handler = route.handler
+		if write != nil && route.method != http.MethodGet {
+			h = write(h)
+		}
+		if guard != nil {
+			h = guard(h)
+		}
+		ctx := NewContextWithValidURL(r.Context(), valid)
+		return h.ServeHTTP(w, r.WithContext(ctx))
+	}
+}
+
+func apiList(w http.ResponseWriter, r *http.Request) (int, error) {
+	valid, _ := ValidURLFromCtx(r.Context())
+	s, _ := StoreFromCtx(r.Context())
+	fi, err := s.Stat(valid.Dir)
+	if err != nil {
+		return writeAPIError(w, err)
+	}
+	if !fi.IsDir() {
+		return writeAPIStatus(w, http.StatusBadRequest, "not a folder")
+	}
+	fInfos, err := s.List(valid.Dir)
+	if err != nil {
+		return writeAPIError(w, err)
+	}
+	files, folders := filterFiles(fInfos)
+	folder := APIFolder{Path: valid.Dir, Folders: folders, Files: make([]APIFile, 0, len(files))}
+	for _, e := range fileEntries(s, valid.Dir, files, true) {
+		folder.Files = append(folder.Files, APIFile{Name: e.Name, Title: e.Title})
+	}
+	return writeJSON(w, http.StatusOK, folder)
+}
+
+// apiPage convert p to an APIPage.
+func apiPage(p *Page) APIPage {
+	page := APIPage{Path: strings.TrimPrefix(p.Path, "/"), Title: p.Title, Binary: p.Binary}
+	if !p.Binary {
+		page.Body = string(p.Body)
+	}
+	return page
+}
+
+func apiView(w http.ResponseWriter, r *http.Request) (int, error) {
+	valid, _ := ValidURLFromCtx(r.Context())
+	s, _ := StoreFromCtx(r.Context())
+	if fi, err := s.Stat(PagePathFromValidURL(valid)); err == nil && fi.IsDir() {
+		return writeAPIStatus(w, http.StatusBadRequest, "not a file")
+	}
+	p, err := LoadPage(s, valid)
+	if err != nil {
+		return writeAPIError(w, err)
+	}
+	return writeJSON(w, http.StatusOK, apiPage(p))
+}
+
+func apiSave(w http.ResponseWriter, r *http.Request) (int, error) {
+	valid, _ := ValidURLFromCtx(r.Context())
+	s, _ := StoreFromCtx(r.Context())
+	var req struct {
+		Body *string `json:"body"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Body == nil {
+		return writeAPIStatus(w, http.StatusBadRequest, "expected a JSON object with a body")
+	}
+	name := PagePathFromValidURL(valid)
+	code := http.StatusOK
+	fi, err := s.Stat(name)
+	switch {
+	case os.IsNotExist(err):
+		code = http.StatusCreated
+	case err != nil:
+		return writeAPIError(w, err)
+	case fi.IsDir():
+		return writeAPIError(w, &os.PathError{Op: "save", Path: name, Err: os.ErrExist})
+	}
+	p := NewPage(s, valid, []byte(*req.Body))
+	if err := p.save(); err != nil {
+		return writeAPIError(w, err)
+	}
+	p, err = LoadPage(s, valid)
+	if err != nil {
+		return writeAPIError(w, err)
+	}
+	return writeJSON(w, code, apiPage(p))
+}
+
+func apiNew(w http.ResponseWriter, r *http.Request) (int, error) {
+	valid, _ := ValidURLFromCtx(r.Context())
+	s, _ := StoreFromCtx(r.Context())
+	var req struct {
+		Name   string `json:"name"`
+		Folder bool   `json:"folder"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return writeAPIStatus(w, http.StatusBadRequest, "expected a JSON object with a name")
+	}
+	if !validName.MatchString(req.Name) {
+		return writeAPIStatus(w, http.StatusBadRequest, "invalid name")
+	}
+	fi, err := s.Stat(valid.Dir)
+	if err != nil {
+		return writeAPIError(w, err)
+	}
+	if !fi.IsDir() {
+		return writeAPIStatus(w, http.StatusBadRequest, "not a folder")
+	}
+	name := valid.Dir + req.Name
+	if _, err := s.Stat(name); err == nil {
+		return writeAPIError(w, &os.PathError{Op: "new", Path: name, Err: os.ErrExist})
+	}
+	if req.Folder {
+		if err := s.Mkdir(name); err != nil {
+			return writeAPIError(w, err)
+		}
+		w.Header().Set("Location", BasePathFromCtx(r.Context())+"/api/v1/list/"+name+"/")
+		return writeJSON(w, http.StatusCreated, APIFolder{Path: name + "/", Folders: []string{}, Files: []APIFile{}})
+	}
+	if err := s.Write(name, nil); err != nil {
+		return writeAPIError(w, err)
+	}
+	w.Header().Set("Location", BasePathFromCtx(r.Context())+"/api/v1/view/"+name)
+	return writeJSON(w, http.StatusCreated, APIPage{Path: name})
+}
+
+func apiFolder(w http.ResponseWriter, r *http.Request) (int, error) {
+	valid, _ := ValidURLFromCtx(r.Context())
+	s, _ := StoreFromCtx(r.Context())
+	name := strings.TrimPrefix(PagePathFromValidURL(valid), "/")
+	if _, err := s.Stat(name); err == nil {
+		return writeAPIError(w, &os.PathError{Op: "folder", Path: name, Err: os.ErrExist})
+	}
+	if err := NewFolder(s, valid); err != nil {
+		return writeAPIError(w, err)
+	}
+	w.Header().Set("Location", BasePathFromCtx(r.Context())+"/api/v1/list/"+name+"/")
+	return writeJSON(w, http.StatusCreated, APIFolder{Path: name + "/", Folders: []string{}, Files: []APIFile{}})
+}
@@ -0,0 +1,128 @@
+package mngr
+
+import (
+	"context"
+	"net/http"
+	"path"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// ArchiveEntry is a page displayed by the archive.
+type ArchiveEntry struct {
+	Path  string
+	Title string
+	Date  time.Time
+}
+
+// ArchiveMonth groups the pages of a month, newest first.
+type ArchiveMonth struct {
+	Month time.Month
+	Pages []ArchiveEntry
+}
+
+// ArchiveYear groups the months of a year, newest first.
+type ArchiveYear struct {
+	Year   int
+	Months []ArchiveMonth
+}
+
+// archiveIndex contains the pages of a folder, dated ones sorted from
+// newest to oldest.
+type archiveIndex struct {
+	dated   []ArchiveEntry
+	undated []ArchiveEntry
+}
+
+// buildArchiveIndex read the front matter date of every readable text page
+// located under dir in s, see walkFiles.
+func buildArchiveIndex(ctx context.Context, s Store, dir string, readable func(p string) bool, workers int) (*archiveIndex, error) {
+	idx := &archiveIndex{}
+	err := walkPages(ctx, s, dir, readable, workers, func(p string, body []byte) error {
+		if !isText(body) {
+			return nil
+		}
+		e := ArchiveEntry{Path: p, Title: PageTitle(body)}
+		if e.Title == "" {
+			e.Title = path.Base(p)
+		}
+		t, err := ParseDate(ParseFrontMatter(body)["date"])
+		if err != nil {
+			idx.undated = append(idx.undated, e)
+			return nil
+		}
+		e.Date = t
+		idx.dated = append(idx.dated, e)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.SliceStable(idx.dated, func(i, j int) bool {
+		return idx.dated[i].Date.After(idx.dated[j].Date)
+	})
+	return idx, nil
+}
+
+// groupArchive group entries sorted from newest to oldest by year and month.
+// A year or month of 0 doesn't filter anything.
+func groupArchive(entries []ArchiveEntry, year int, month time.Month) []ArchiveYear {
+	var years []ArchiveYear
+	for _, e := range entries {
+		y, m := e.Date.Year(), e.Date.Month()
+		if (year != 0 && y != year) || (month != 0 && m != month) {
+			continue
+		}
+		if len(years) == 0 || years[len(years)-1].Year != y {
+			years = append(years, ArchiveYear{Year: y})
+		}
+		cur := &years[len(years)-1]
+		if len(cur.Months) == 0 || cur.Months[len(cur.Months)-1].Month != m {
+			cur.Months = append(cur.Months, ArchiveMonth{Month: m})
+		}
+		months := cur.Months
+		months[len(months)-1].Pages = append(months[len(months)-1].Pages, e)
+	}
+	return years
+}
+
+// MakeArchiveHandler return an handler rendering the pages of the requested
+// folder grouped by the year and month of their front matter 'date', with
+// archive.html. The 'year' and 'month' query values restrict the archive,
+// pages without a valid date are listed apart when no filter is given.
+// The date index is cached per folder, and user, for ttl.
+func MakeArchiveHandler(s Store, ttl time.Duration, workers int) ContextHandlerFunc {
+	cache := newTTLCache(ttl)
+	return func(ctx context.Context, w http.ResponseWriter, r *http.Request) (int, error) {
+		valid, _ := ValidURLFromCtx(ctx)
+		v, err := cache.get(userCacheKey(ctx, valid.Dir), func() (interface{}, error) {
+			return buildArchiveIndex(ctx, s, valid.Dir, readFilter(ctx), workers)
+		})
+		if err != nil {
+			return 0, err
+		}
+		idx := v.(*archiveIndex)
+		year, _ := strconv.Atoi(r.URL.Query().Get("year"))
+		month, _ := strconv.Atoi(r.URL.Query().Get("month"))
+		if month < 0 || month > 12 {
+			month = 0
+		}
+		var undated []ArchiveEntry
+		if year == 0 && month == 0 {
+			undated = idx.undated
+		}
+		p := &struct {
+			TemplateInfo
+			Years   []ArchiveYear
+			Undated []ArchiveEntry
+		}{
+			TemplateInfo: newTemplateInfo(r, valid),
+			Years:        groupArchive(idx.dated, year, time.Month(month)),
+			Undated:      undated,
+		}
+		t, _ := TemplateFromCtx(ctx)
+		err = t.ExecuteTemplate(w, "archive.html", p)
+		return 200, err
+	}
+}
@@ -0,0 +1,33 @@
+package mngr
+
+import (
+	"net/http"
+)
+
+// MakeBrokenAssetsHandler return an handler listing, as JSON, the relative
+// asset references of a page (images and non page files) which don't
+// resolve to an existing file. The list is empty when every asset exists.
+func MakeBrokenAssetsHandler(s Store) HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) (int, error) {
+		valid, _ := ValidURLFromCtx(r.Context())
+		body, err := s.Read(PagePathFromValidURL(valid))
+		if err != nil {
+			return 0, err
+		}
+		missing := []Link{}
+		for _, l := range ExtractLinks(body) {
+			p, ok := relativeTarget(l.Target)
+			if !ok || !isAsset(l, p) {
+				continue
+			}
+			resolved, ok := resolveLink(valid.Dir, p)
+			if ok {
+				_, err = s.Stat(resolved)
+			}
+			if !ok || err != nil {
+				missing = append(missing, l)
+			}
+		}
+		return writeJSON(w, http.StatusOK, missing)
+	}
+}
@@ -0,0 +1,209 @@
+package mngr
+
+import (
+	"bufio"
+	"context"
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/subtle"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+type userCtxKey int
+
+var (
+	userKey      = userCtxKey(0)
+	challengeKey = userCtxKey(1)
+)
+
+// UserFromCtx extract the name of the user authenticated by
+// MakeBasicAuthMiddleware or MakeSessionMiddleware from a context.
+func UserFromCtx(ctx context.Context) (string, bool) {
+	user, ok := ctx.Value(userKey).(string)
+	return user, ok
+}
+
+// Htpasswd holds the credentials of an htpasswd file. The passwords can be
+// hashed with bcrypt, the Apache MD5 ($apr1$) or SHA1 ({SHA}) schemes, or
+// stored in plain text.
+type Htpasswd struct {
+	users map[string]string
+}
+
+// ParseHtpasswd read the "user:password" lines of an htpasswd file. Blank
+// lines and lines starting with # are ignored.
+func ParseHtpasswd(r io.Reader) (*Htpasswd, error) {
+	h := &Htpasswd{users: make(map[string]string)}
+	scanner := bufio.NewScanner(r)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || line[0] == '#' {
+			continue
+		}
+		i := strings.IndexByte(line, ':')
+		if i <= 0 {
+			return nil, fmt.Errorf("htpasswd: line %d: missing user", n)
+		}
+		h.users[line[:i]] = line[i+1:]
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return h, nil
+}
+
+// LoadHtpasswd read the htpasswd file located at path.
+func LoadHtpasswd(path string) (*Htpasswd, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return ParseHtpasswd(f)
+}
+
+// Check report whether password is the password of user.
+func (h *Htpasswd) Check(user, password string) bool {
+	hash, ok := h.users[user]
+	if !ok {
+		return false
+	}
+	switch {
+	case strings.HasPrefix(hash, "$2y$"), strings.HasPrefix(hash, "$2a$"), strings.HasPrefix(hash, "$2b$"):
+		return bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)) == nil
+	case strings.HasPrefix(hash, apr1Magic):
+		salt := strings.TrimPrefix(hash, apr1Magic)
+		if i := strings.IndexByte(salt, '$'); i >= 0 {
+			salt = salt[:i]
+		}
+		return secureEqual(apr1(password, salt), hash)
+	case strings.HasPrefix(hash, "{SHA}"):
+		sum := sha1.Sum([]byte(password))
+		return secureEqual("{SHA}"+base64.StdEncoding.EncodeToString(sum[:]), hash)
+	}
+	return secureEqual(password, hash)
+}
+
+// secureEqual compare a and b in constant time.
+func secureEqual(a, b string) bool {
+	return subtle.ConstantTimeCompare([]byte(a), []byte(b)) == 1
+}
+
+const apr1Magic = "$apr1$"
+
+// apr1 hash password with the Apache variant of the MD5 crypt algorithm.
+func apr1(password, salt string) string {
+	if len(salt) > 8 {
+		salt = salt[:8]
+	}
+	pw := []byte(password)
+	d := md5.New()
+	d.Write(pw)
+	d.Write([]byte(apr1Magic))
+	d.Write([]byte(salt))
+	alt := md5.Sum([]byte(password + salt + password))
+	for i := len(pw); i > 0; i -= 16 {
+		if i > 16 {
+			d.Write(alt[:])
+		} else {
+			d.Write(alt[:i])
+		}
+	}
+	for i := len(pw); i > 0; i >>= 1 {
+		if i&1 != 0 {
+			d.Write([]byte{0})
+		} else {
+			d.Write(pw[:1])
+		}
+	}
+	sum := d.Sum(nil)
+	for i := 0; i < 1000; i++ {
+		d := md5.New()
+		if i&1 != 0 {
+			d.Write(pw)
+		} else {
+			d.Write(sum)
+		}
+		if i%3 != 0 {
+			d.Write([]byte(salt))
+		}
+		if i%7 != 0 {
+			d.Write(pw)
+		}
+		if i&1 != 0 {
+			d.Write(sum)
+		} else {
+			d.Write(pw)
+		}
+		sum = d.Sum(nil)
+	}
+
+	const itoa64 = "./0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz"
+	var b strings.Builder
+	encode := func(v uint32, n int) {
+		for ; n > 0; n-- {
+			b.WriteByte(itoa64[v&0x3f])
+			v >>= 6
+		}
+	}
+	for _, i := range [][3]int{{0, 6, 12}, {1, 7, 13}, {2, 8, 14}, {3, 9, 15}, {4, 10, 5}} {
+		encode(uint32(sum[i[0]])<<16|uint32(sum[i[1]])<<8|uint32(sum[i[2]]), 4)
+	}
+	encode(uint32(sum[11]), 2)
+	return apr1Magic + salt + "$" + b.String()
+}
+
+// MakeBasicAuthMiddleware create a middleware requiring the credentials of
+// an user accepted by check, with HTTP Basic authentication. The name of
+// the user is added to the request's context, see UserFromCtx. Other
+// requests are answered with 401 and a challenge for realm.
+func MakeBasicAuthMiddleware(realm string, check func(user, password string) bool) Middleware {
+	challenge := fmt.Sprintf("Basic realm=%q, charset=\"UTF-8\"", realm)
+	return func(h Handler) Handler {
+		return HandlerFunc(func(w http.ResponseWriter, r *http.Request) (int, error) {
+			user, password, ok := r.BasicAuth()
+			if !ok || !check(user, password) {
+				w.Header().Set("WWW-Authenticate", challenge)
+				w.Header().Set("Content-Type", "text/plain")
+				w.WriteHeader(http.StatusUnauthorized)
+				w.Write([]byte("unauthorized"))
+				return http.StatusUnauthorized, nil
+			}
+			ctx := context.WithValue(r.Context(), userKey, user)
+			return h.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// MakeOptionalBasicAuthMiddleware works like MakeBasicAuthMiddleware but
+// hand the requests without credentials over anonymously. The challenge is
+// added to their context, for MakeACLMiddleware to answer 401 instead of
+// 403 when the anonymous users aren't granted the role required.
+func MakeOptionalBasicAuthMiddleware(realm string, check func(user, password string) bool) Middleware {
+	challenge := fmt.Sprintf("Basic realm=%q, charset=\"UTF-8\"", realm)
+	basic := MakeBasicAuthMiddleware(realm, check)
+	return func(h Handler) Handler {
+		authenticated := basic(h)
+		return HandlerFunc(func(w http.ResponseWriter, r *http.Request) (int, error) {
+			if _, _, ok := r.BasicAuth(); ok {
+				return authenticated.ServeHTTP(w, r)
+			}
+			ctx := context.WithValue(r.Context(), challengeKey, challenge)
+			return h.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// challengeFromCtx return the challenge added to a context by
+// MakeOptionalBasicAuthMiddleware, or an empty string.
+func challengeFromCtx(ctx context.Context) string {
+	challenge, _ := ctx.Value(challengeKey).(string)
+	return challenge
+}
@@ -0,0 +1,50 @@
+package mngr
+
+import (
+	"context"
+	"net/http"
+	"strings"
+)
+
+type basePathCtxKey int
+
+var basePathKey = basePathCtxKey(0)
+
+// BasePathFromCtx extract the path prefix added by MakeBasePathMiddleware
+// from a context. It return an empty string when the wiki is served at the
+// root.
+func BasePathFromCtx(ctx context.Context) string {
+	base, _ := ctx.Value(basePathKey).(string)
+	return base
+}
+
+// cleanBasePath return base with a leading slash and without trailing one,
+// "/" being the root, an empty string.
+func cleanBasePath(base string) string {
+	base = strings.TrimRight(base, "/")
+	if base != "" && base[0] != '/' {
+		base = "/" + base
+	}
+	return base
+}
+
+// MakeBasePathMiddleware create a middleware adding base, the path under
+// which the wiki is mounted like "/wiki", to the request's context. The
+// links of the templates and the redirects are then prefixed with base.
+// The prefix must be stripped from the URL of the requests, with
+// http.StripPrefix, as the handlers expect paths like /view/page.md.
+func MakeBasePathMiddleware(base string) Middleware {
+	base = cleanBasePath(base)
+	return func(h Handler) Handler {
+		return HandlerFunc(func(w http.ResponseWriter, r *http.Request) (int, error) {
+			ctx := context.WithValue(r.Context(), basePathKey, base)
+			return h.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// redirect reply to r with a redirect to url, a path of the wiki, prefixed
+// with the base path of r.
+func redirect(w http.ResponseWriter, r *http.Request, url string, code int) {
+	http.Redirect(w, r, BasePathFromCtx(r.Context())+url, code)
+}
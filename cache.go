@@ -0,0 +1,97 @@
+package mngr
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// errComputing is the error of a computation which didn't return.
+var errComputing = errors.New("cache: computation interrupted")
+
+// ttlCache keep the result of expensive computations, like tree walks,
+// for a limited duration.
+type ttlCache struct {
+	ttl time.Duration
+
+	mu      sync.Mutex
+	entries map[string]ttlEntry
+	// computing holds the computations running, by key.
+	computing map[string]*ttlCall
+}
+
+type ttlEntry struct {
+	value   interface{}
+	expires time.Time
+}
+
+// ttlCall is a running computation, done is closed when it ends.
+type ttlCall struct {
+	done  chan struct{}
+	value interface{}
+	err   error
+}
+
+// newTTLCache create a ttlCache whose entries expire after ttl.
+// With a ttl of 0 or less nothing is cached.
+func newTTLCache(ttl time.Duration) *ttlCache {
+	return &ttlCache{
+		ttl:       ttl,
+		entries:   make(map[string]ttlEntry),
+		computing: make(map[string]*ttlCall),
+	}
+}
+
+// get return the value cached for key, computing it with fn when it is
+// missing or expired. A single computation of key runs at a time, the
+// concurrent calls wait for its value. Errors returned by fn are not
+// cached, the waiting calls compute the value again.
+func (c *ttlCache) get(key string, fn func() (interface{}, error)) (interface{}, error) {
+	if c.ttl <= 0 {
+		return fn()
+	}
+	c.mu.Lock()
+	for {
+		if e, ok := c.entries[key]; ok && time.Now().Before(e.expires) {
+			c.mu.Unlock()
+			return e.value, nil
+		}
+		call, ok := c.computing[key]
+		if !ok {
+			break
+		}
+		c.mu.Unlock()
+		<-call.done
+		if call.err == nil {
+			return call.value, nil
+		}
+		c.mu.Lock()
+	}
+	// The error is kept when fn panics, for the waiting calls to retry.
+	call := &ttlCall{done: make(chan struct{}), err: errComputing}
+	c.computing[key] = call
+	c.mu.Unlock()
+	defer func() {
+		c.mu.Lock()
+		// A value computed while key was dropped isn't cached.
+		if c.computing[key] == call {
+			delete(c.computing, key)
+			if call.err == nil {
+				c.entries[key] = ttlEntry{value: call.value, expires: time.Now().Add(c.ttl)}
+			}
+		}
+		c.mu.Unlock()
+		close(call.done)
+	}()
+	call.value, call.err = fn()
+	return call.value, call.err
+}
+
+// drop remove the value cached for key. The value being computed, if any,
+// won't be cached.
+func (c *ttlCache) drop(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.entries, key)
+	delete(c.computing, key)
+}
@@ -0,0 +1,69 @@
+package mngr
+
+import (
+	"net/http"
+)
+
+// CachePolicy holds the Cache-Control headers of the classes of routes. An
+// empty header is not sent.
+type CachePolicy struct {
+	// Static is the header of the static assets.
+	Static string
+	// View is the header of the routes displaying the wiki.
+	View string
+	// Edit is the header of the routes modifying the wiki, their forms,
+	// and of the API.
+	Edit string
+}
+
+// DefaultCachePolicy keep the static assets a day, make the browsers
+// revalidate the pages on every visit, which the ETag of the views makes
+// cheap, and never store the editing routes.
+var DefaultCachePolicy = CachePolicy{
+	Static: "public, max-age=86400",
+	View:   "private, no-cache",
+	Edit:   "no-store",
+}
+
+// cacheControlWriter is an http.ResponseWriter setting the Cache-Control
+// header of the successful responses which don't have one.
+type cacheControlWriter struct {
+	http.ResponseWriter
+	value string
+	wrote bool
+}
+
+func (w *cacheControlWriter) WriteHeader(code int) {
+	if !w.wrote && code < http.StatusBadRequest && w.Header().Get("Cache-Control") == "" {
+		w.Header().Set("Cache-Control", w.value)
+	}
+	w.wrote = true
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *cacheControlWriter) Write(b []byte) (int, error) {
+	if !w.wrote {
+		w.WriteHeader(http.StatusOK)
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+// Unwrap return the wrapped http.ResponseWriter, for http.ResponseController.
+func (w *cacheControlWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// MakeCacheControlMiddleware create a middleware sending value as the
+// Cache-Control header of the responses. Errors, and the responses whose
+// handler set its own header, are left untouched. An empty value disables
+// the middleware.
+func MakeCacheControlMiddleware(value string) Middleware {
+	return func(h Handler) Handler {
+		if value == "" {
+			return h
+		}
+		return HandlerFunc(func(w http.ResponseWriter, r *http.Request) (int, error) {
+			return h.ServeHTTP(&cacheControlWriter{ResponseWriter: w, value: value}, r)
+		})
+	}
+}
@@ -1,79 +1,346 @@
 package main
 
 import (
+	"compress/flate"
+	"context"
+	"crypto/rand"
 	"net/http"
 
 	"fmt"
 	"os"
+	"os/signal"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
 
 	"github.com/aitva/mngr"
+	"github.com/aitva/mngr/davfs"
+	"github.com/aitva/mngr/gitstore"
+	"github.com/aitva/mngr/oidcauth"
+	"github.com/aitva/mngr/s3store"
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
 )
 
 const (
 	dataPath = "data"
-	tmplPath = "tmpl"
+	// themesPath is the folder holding the themes.
+	themesPath = "themes"
+	// sessionMaxAge is the duration of the login sessions.
+	sessionMaxAge = 7 * 24 * time.Hour
+	// rateBurst is the number of requests a client can make at once when
+	// the rate is limited.
+	rateBurst = 20
+	// shutdownTimeout is the longest time waited for the requests in flight
+	// on shutdown.
+	shutdownTimeout = 30 * time.Second
 )
 
-// StatusWriter is an http.ResponseWriter which
-// captures the status set with WriteHeader.
-type StatusWriter struct {
-	http.ResponseWriter
-	status int
+// newStore return the store holding the pages and the middleware adding it
+// to requests. The local data folder is used unless MNGR_S3_BUCKET is set,
+// pages are then kept in that bucket of the S3 server at MNGR_S3_ENDPOINT,
+// authenticated with MNGR_S3_ACCESS_KEY and MNGR_S3_SECRET_KEY. When MNGR_GIT
+// is set the data folder is a git repository and every change is committed.
+func newStore() (mngr.Store, mngr.Middleware, error) {
+	if os.Getenv("MNGR_GIT") != "" {
+		s, err := gitstore.Open(dataPath)
+		if err != nil {
+			return nil, nil, err
+		}
+		return s, gitstore.MakeCommitMiddleware(s, commitAuthor), nil
+	}
+	bucket := os.Getenv("MNGR_S3_BUCKET")
+	if bucket == "" {
+		s := mngr.DirStore(dataPath)
+		return s, mngr.MakeStoreMiddleware(s), nil
+	}
+	client, err := minio.New(os.Getenv("MNGR_S3_ENDPOINT"), &minio.Options{
+		Creds:  credentials.NewStaticV4(os.Getenv("MNGR_S3_ACCESS_KEY"), os.Getenv("MNGR_S3_SECRET_KEY"), ""),
+		Secure: true,
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	s := s3store.New(client, bucket)
+	return s, mngr.MakeStoreMiddleware(s), nil
 }
 
-// WriteHeader is a redefinition of http.ResponseWriter.WriteHeader.
-// This function allows us to capture the status set by an handler.
-func (w *StatusWriter) WriteHeader(status int) {
-	w.status = status
-	w.ResponseWriter.WriteHeader(status)
+// commitAuthor return the authenticated user of r as commit author.
+func commitAuthor(r *http.Request) (name, email string) {
+	user, ok := mngr.UserFromCtx(r.Context())
+	if !ok {
+		return "", ""
+	}
+	return user, user + "@mngr"
 }
 
-// fileHandler creates a fileserver and captures the response
-// status for logging.
-func fileHandler(w http.ResponseWriter, r *http.Request) (int, error) {
-	sw := &StatusWriter{ResponseWriter: w}
-	h := http.FileServer(http.Dir("static"))
-	h = http.StripPrefix("/static/", h)
-	h.ServeHTTP(sw, r)
-	return sw.status, nil
+// authConfig holds the middlewares authenticating the requests.
+type authConfig struct {
+	// write protects the routes modifying the wiki, read the other ones.
+	write, read mngr.Middleware
+	// client protects the routes used by scripts and native clients, which
+	// can't log in with a form.
+	client mngr.Middleware
+	// sessions is set when users log in with the login page.
+	sessions *mngr.Sessions
+	// provider is set when the login is delegated to an OIDC provider.
+	provider mngr.AuthProvider
 }
 
-func indexHandler(w http.ResponseWriter, r *http.Request) (int, error) {
-	http.Redirect(w, r, "/list/", http.StatusFound)
-	return http.StatusFound, nil
+// newAuth return the authentication of the routes. When MNGR_HTPASSWD is
+// set, the users of this htpasswd file are required to modify the wiki, and
+// to read it too when MNGR_PRIVATE is set. They log in with HTTP Basic
+// authentication or, when MNGR_LOGIN is set, with the login page. With an
+// ACL at MNGR_ACL, the reading requests are authenticated too when they
+// carry Basic credentials, and challenged when the ACL denies them. Setting
+// MNGR_OIDC_ISSUER delegate the login page to this OpenID Connect provider,
+// with the client MNGR_OIDC_CLIENT_ID, MNGR_OIDC_CLIENT_SECRET and
+// MNGR_OIDC_REDIRECT_URL, for the comma separated MNGR_OIDC_USERS if set.
+// Sessions are signed with MNGR_SESSION_KEY, or a random key lost on restart.
+func newAuth() (*authConfig, error) {
+	public := func(h mngr.Handler) mngr.Handler { return h }
+	a := &authConfig{write: public, read: public}
+	var users mngr.UserStore
+	if path := os.Getenv("MNGR_HTPASSWD"); path != "" {
+		htpasswd, err := mngr.LoadHtpasswd(path)
+		if err != nil {
+			return nil, err
+		}
+		basic := mngr.MakeBasicAuthMiddleware("mngr", htpasswd.Check)
+		a.write, a.client = basic, basic
+		if os.Getenv("MNGR_ACL") != "" {
+			a.read = mngr.MakeOptionalBasicAuthMiddleware("mngr", htpasswd.Check)
+		}
+		if os.Getenv("MNGR_LOGIN") != "" {
+			users = htpasswd
+		}
+	}
+	issuer := os.Getenv("MNGR_OIDC_ISSUER")
+	if users != nil || issuer != "" {
+		key := []byte(os.Getenv("MNGR_SESSION_KEY"))
+		if len(key) == 0 {
+			key = make([]byte, 32)
+			if _, err := rand.Read(key); err != nil {
+				return nil, err
+			}
+		}
+		a.sessions = mngr.NewSessions(users, key, sessionMaxAge)
+		if issuer != "" {
+			var allowed []string
+			if u := os.Getenv("MNGR_OIDC_USERS"); u != "" {
+				allowed = strings.Split(u, ",")
+			}
+			p, err := oidcauth.New(context.Background(), oidcauth.Config{
+				Issuer:       issuer,
+				ClientID:     os.Getenv("MNGR_OIDC_CLIENT_ID"),
+				ClientSecret: os.Getenv("MNGR_OIDC_CLIENT_SECRET"),
+				RedirectURL:  os.Getenv("MNGR_OIDC_REDIRECT_URL"),
+				Users:        allowed,
+			})
+			if err != nil {
+				return nil, err
+			}
+			a.provider = p
+		}
+		session := mngr.MakeSessionMiddleware(a.sessions)
+		require := mngr.MakeRequireUserMiddleware("/login")
+		a.read = session
+		a.write = mngr.Chain(session, require)
+	}
+	if a.client == nil {
+		a.client = a.write
+	}
+	if os.Getenv("MNGR_PRIVATE") != "" {
+		a.read = a.write
+	}
+	return a, nil
 }
 
-func main() {
-	const addr = ":8080"
+// newACL return the options checking the roles of the users, granted by
+// the ACL file at MNGR_ACL. Everyone can do anything when it isn't set. When
+// MNGR_READONLY is set, the requests modifying the wiki are rejected too.
+func newACL() ([]mngr.ServerOption, error) {
+	var opts []mngr.ServerOption
+	if path := os.Getenv("MNGR_ACL"); path != "" {
+		a, err := mngr.LoadACL(path)
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, mngr.ServerACL(a))
+	}
+	if os.Getenv("MNGR_READONLY") != "" {
+		opts = append(opts, mngr.ServerReadOnly())
+	}
+	return opts, nil
+}
 
-	log := mngr.MakeLogMiddleware(os.Stdout)
-	tmpl := mngr.MakeTemplateMiddleware(tmplPath)
-	valid := mngr.MakeValidURLMiddleware()
-	validFolder := mngr.MakeValidFolderMiddleware(dataPath)
-	createHandler := mngr.MakeNewHandler()
+// newRateLimit return the options limiting the requests of every client to
+// MNGR_RATE_LIMIT per second. The clients of the proxies listed in
+// MNGR_TRUSTED_PROXIES are read from X-Forwarded-For or X-Real-IP, in the
+// logs too. Requests are not limited when MNGR_RATE_LIMIT isn't set.
+func newRateLimit() ([]mngr.ServerOption, error) {
+	trusted, err := mngr.ParseCIDRs(os.Getenv("MNGR_TRUSTED_PROXIES"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid MNGR_TRUSTED_PROXIES: %v", err)
+	}
+	opts := []mngr.ServerOption{mngr.ServerTrustedProxies(trusted)}
+	limit := os.Getenv("MNGR_RATE_LIMIT")
+	if limit == "" {
+		return opts, nil
+	}
+	rate, err := strconv.ParseFloat(limit, 64)
+	if err != nil || rate <= 0 {
+		return nil, fmt.Errorf("invalid MNGR_RATE_LIMIT %q", limit)
+	}
+	return append(opts, mngr.ServerRateLimit(rate, rateBurst)), nil
+}
 
-	index := log(mngr.HandlerFunc(indexHandler))
-	list := log(tmpl(validFolder(mngr.MakeListHandler(dataPath))))
-	view := log(tmpl(valid(mngr.HandlerFunc(mngr.ViewHandler))))
-	edit := log(tmpl(valid(mngr.HandlerFunc(mngr.EditHandler))))
-	save := log(tmpl(valid(mngr.HandlerFunc(mngr.SaveHandler))))
-	folder := log(tmpl(valid(mngr.HandlerFunc(mngr.FolderHandler))))
-	new := log(tmpl(valid(mngr.HandlerFunc(createHandler))))
-	filesrv := log(mngr.HandlerFunc(fileHandler))
+// newLog return the option logging the requests to the standard output, in
+// the format of MNGR_LOG_FORMAT: json, common, combined or an Apache log
+// format.
+func newLog() mngr.ServerOption {
+	var logOpts []mngr.LogOption
+	switch format := os.Getenv("MNGR_LOG_FORMAT"); format {
+	case "":
+	case "json":
+		logOpts = append(logOpts, mngr.LogJSON())
+	case "common":
+		logOpts = append(logOpts, mngr.LogFormat(mngr.CommonLogFormat))
+	case "combined":
+		logOpts = append(logOpts, mngr.LogFormat(mngr.CombinedLogFormat))
+	default:
+		logOpts = append(logOpts, mngr.LogFormat(format))
+	}
+	return mngr.ServerLog(os.Stdout, logOpts...)
+}
 
-	http.Handle("/", index)
-	http.Handle("/list/", list)
-	http.Handle("/view/", view)
-	http.Handle("/edit/", edit)
-	http.Handle("/save/", save)
-	http.Handle("/folder/", folder)
-	http.Handle("/new/", new)
-	http.Handle("/static/", filesrv)
+// newTLS return the options serving the wiki over TLS and reports whether
+// it is enabled. The certificate and key are read from MNGR_TLS_CERT and
+// MNGR_TLS_KEY, or obtained from Let's Encrypt for the comma separated
+// MNGR_AUTOCERT hosts and kept in MNGR_AUTOCERT_CACHE, "certs" by default.
+// Let's Encrypt must reach the server on :443, and HTTP is redirected from
+// :80, unless MNGR_ADDR is set.
+func newTLS() ([]mngr.ServerOption, bool) {
+	addr := os.Getenv("MNGR_ADDR")
+	hosts := os.Getenv("MNGR_AUTOCERT")
+	if hosts == "" {
+		var opts []mngr.ServerOption
+		if addr != "" && !strings.HasPrefix(addr, "unix:") {
+			opts = append(opts, mngr.ServerAddr(addr))
+		}
+		return opts, os.Getenv("MNGR_TLS_CERT") != ""
+	}
+	cache := os.Getenv("MNGR_AUTOCERT_CACHE")
+	if cache == "" {
+		cache = "certs"
+	}
+	opts := []mngr.ServerOption{mngr.ServerAutocert(cache, strings.Split(hosts, ",")...)}
+	if addr == "" {
+		opts = append(opts, mngr.ServerAddr(":443"), mngr.ServerRedirectHTTP(":80"))
+	} else {
+		opts = append(opts, mngr.ServerAddr(addr))
+	}
+	return opts, true
+}
 
-	fmt.Println("Listening on " + addr)
-	err := http.ListenAndServe(addr, nil)
+func main() {
+	mngr.FSRetry.Log = os.Stdout
+	opts := []mngr.ServerOption{newLog(), mngr.ServerCompress(flate.DefaultCompression)}
+	tlsOpts, useTLS := newTLS()
+	opts = append(opts, tlsOpts...)
+	if theme := os.Getenv("MNGR_THEME"); theme != "" {
+		dir := filepath.Join(themesPath, theme)
+		if fi, err := os.Stat(dir); strings.ContainsAny(theme, `/\`) || err != nil || !fi.IsDir() {
+			fmt.Fprintf(os.Stderr, "unknown theme %q, not a folder of %s\n", theme, themesPath)
+			os.Exit(1)
+		}
+		opts = append(opts, mngr.ServerTheme(dir))
+	}
+	if os.Getenv("MNGR_DEV") != "" {
+		opts = append(opts, mngr.ServerTemplateReload())
+	}
+	if base := os.Getenv("MNGR_BASE_PATH"); base != "" {
+		opts = append(opts, mngr.ServerBasePath(base))
+	}
+	store, stored, err := newStore()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	opts = append(opts, mngr.ServerStore(store, stored))
+	if os.Getenv("MNGR_S3_BUCKET") == "" {
+		opts = append(opts, mngr.ServerHealthCheck(mngr.DirCheck(dataPath)))
+	}
+	access, err := newAuth()
 	if err != nil {
 		fmt.Fprintln(os.Stderr, err)
 		os.Exit(1)
 	}
+	opts = append(opts, mngr.ServerAuth(access.write, access.read, access.client))
+	if access.sessions != nil {
+		opts = append(opts, mngr.ServerSessions(access.sessions, access.provider))
+	}
+	aclOpts, err := newACL()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	limitOpts, err := newRateLimit()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	opts = append(append(opts, aclOpts...), limitOpts...)
+
+	srv := mngr.NewServer(dataPath, opts...)
+	if os.Getenv("MNGR_WEBDAV") != "" {
+		srv.HandleClient("/dav/", davfs.NewHandler(store, "/dav", srv.Invalidate))
+	}
+	if debugAddr := os.Getenv("MNGR_DEBUG_ADDR"); debugAddr != "" {
+		go func() {
+			fmt.Println("Debug endpoints listening on " + debugAddr)
+			if err := http.ListenAndServe(debugAddr, mngr.MakeDebugHandler("/debug/")); err != nil {
+				fmt.Fprintln(os.Stderr, err)
+			}
+		}()
+	}
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, os.Interrupt, syscall.SIGTERM)
+	done := make(chan error, 1)
+	go func() {
+		<-stop
+		fmt.Println("Shutting down")
+		ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer cancel()
+		done <- srv.Shutdown(ctx)
+	}()
+	listen := srv.ListenAndServe
+	if path, ok := strings.CutPrefix(os.Getenv("MNGR_ADDR"), "unix:"); ok {
+		mode := os.FileMode(0660)
+		if m := os.Getenv("MNGR_SOCKET_MODE"); m != "" {
+			n, err := strconv.ParseUint(m, 8, 32)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "invalid MNGR_SOCKET_MODE %q\n", m)
+				os.Exit(1)
+			}
+			mode = os.FileMode(n)
+		}
+		listen = func() error {
+			return srv.ListenAndServeUnix(path, mode)
+		}
+	} else if useTLS {
+		listen = func() error {
+			return srv.ListenAndServeTLS(os.Getenv("MNGR_TLS_CERT"), os.Getenv("MNGR_TLS_KEY"))
+		}
+	}
+	if err := listen(); err != http.ErrServerClosed {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	if err := <-done; err != nil {
+		fmt.Fprintln(os.Stderr, "shutdown:", err)
+		os.Exit(1)
+	}
 }
@@ -0,0 +1,137 @@
+package mngr
+
+import (
+	"compress/flate"
+	"compress/gzip"
+	"io"
+	"mime"
+	"net/http"
+)
+
+// DefaultCompressTypes are the media types compressed by the middleware of
+// MakeCompressMiddleware when none are given.
+var DefaultCompressTypes = []string{
+	"text/html",
+	"text/plain",
+	"text/css",
+	"text/csv",
+	"text/markdown",
+	"application/json",
+	"application/javascript",
+	"image/svg+xml",
+}
+
+// compressWriter is an http.ResponseWriter compressing the body, when the
+// response turns out to be compressible.
+type compressWriter struct {
+	http.ResponseWriter
+	r        *http.Request
+	encoding string
+	level    int
+	types    map[string]bool
+
+	decided bool
+	enc     io.WriteCloser
+}
+
+// decide choose whether the response of code is compressed, from its
+// headers, and prepare the encoder if it is.
+func (w *compressWriter) decide(code int) {
+	if w.decided {
+		return
+	}
+	w.decided = true
+	h := w.Header()
+	if w.r.Method == http.MethodHead || h.Get("Content-Encoding") != "" || h.Get("Content-Range") != "" {
+		return
+	}
+	if code < http.StatusOK || code == http.StatusNoContent || code == http.StatusPartialContent || code == http.StatusNotModified {
+		return
+	}
+	media, _, err := mime.ParseMediaType(h.Get("Content-Type"))
+	if err != nil || !w.types[media] {
+		return
+	}
+	h.Set("Content-Encoding", w.encoding)
+	h.Add("Vary", "Accept-Encoding")
+	h.Del("Content-Length")
+	if w.encoding == "gzip" {
+		w.enc, _ = gzip.NewWriterLevel(w.ResponseWriter, w.level)
+	} else {
+		w.enc, _ = flate.NewWriter(w.ResponseWriter, w.level)
+	}
+}
+
+func (w *compressWriter) WriteHeader(code int) {
+	w.decide(code)
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *compressWriter) Write(b []byte) (int, error) {
+	if !w.decided {
+		if w.Header().Get("Content-Type") == "" {
+			w.Header().Set("Content-Type", http.DetectContentType(b))
+		}
+		w.WriteHeader(http.StatusOK)
+	}
+	if w.enc == nil {
+		return w.ResponseWriter.Write(b)
+	}
+	return w.enc.Write(b)
+}
+
+// Flush send the compressed data written so far to the client.
+func (w *compressWriter) Flush() {
+	if f, ok := w.enc.(interface{ Flush() error }); ok {
+		f.Flush()
+	}
+	http.NewResponseController(w.ResponseWriter).Flush()
+}
+
+// Unwrap return the wrapped http.ResponseWriter, for http.ResponseController.
+func (w *compressWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// close end the compressed stream, if any.
+func (w *compressWriter) close() error {
+	if w.enc == nil {
+		return nil
+	}
+	return w.enc.Close()
+}
+
+// MakeCompressMiddleware create a middleware compressing the responses with
+// gzip, or deflate, when the client accepts it and their Content-Type is
+// one of types, DefaultCompressTypes when empty. level is a compression
+// level of compress/flate. Responses which already have a Content-Encoding,
+// like the gzipped listings or the precompressed static files, and partial
+// responses are left untouched.
+func MakeCompressMiddleware(level int, types ...string) Middleware {
+	if len(types) == 0 {
+		types = DefaultCompressTypes
+	}
+	compressible := make(map[string]bool, len(types))
+	for _, t := range types {
+		compressible[t] = true
+	}
+	return func(h Handler) Handler {
+		return HandlerFunc(func(w http.ResponseWriter, r *http.Request) (int, error) {
+			encoding := ""
+			switch {
+			case acceptsEncoding(r, "gzip"):
+				encoding = "gzip"
+			case acceptsEncoding(r, "deflate"):
+				encoding = "deflate"
+			default:
+				return h.ServeHTTP(w, r)
+			}
+			cw := &compressWriter{ResponseWriter: w, r: r, encoding: encoding, level: level, types: compressible}
+			code, err := h.ServeHTTP(cw, r)
+			if cerr := cw.close(); err == nil {
+				err = cerr
+			}
+			return code, err
+		})
+	}
+}
@@ -0,0 +1,79 @@
+package mngr
+
+import (
+	"net/http"
+	"os"
+	"regexp"
+	"strings"
+)
+
+var validExt = regexp.MustCompile(`^\.[a-zA-Z0-9]+$`)
+
+type convertedFile struct {
+	From  string `json:"from"`
+	To    string `json:"to"`
+	Error string `json:"error,omitempty"`
+}
+
+// MakeConvertHandler return an handler renaming the files of the requested
+// folder with the extension given by the 'from' value to the 'to' extension,
+// without touching their content. Unless the request is a POST with a non
+// empty 'apply' value, it is a dry run. Files whose new name is invalid or
+// already taken are reported as conflicts and left in place.
+func MakeConvertHandler(s Store) HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) (int, error) {
+		valid, _ := ValidURLFromCtx(r.Context())
+		from := "." + strings.TrimPrefix(r.FormValue("from"), ".")
+		to := "." + strings.TrimPrefix(r.FormValue("to"), ".")
+		if !validExt.MatchString(from) || !validExt.MatchString(to) || from == to {
+			w.Header().Set("Content-Type", "text/plain")
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte("bad request: invalid extensions"))
+			return http.StatusBadRequest, nil
+		}
+		apply := r.Method == http.MethodPost && r.FormValue("apply") != ""
+
+		dir := valid.Dir
+		fInfos, err := s.List(dir)
+		if err != nil {
+			return 0, err
+		}
+		files, _ := filterFiles(fInfos)
+		renamed := []convertedFile{}
+		conflicts := []convertedFile{}
+		for _, name := range files {
+			if !strings.HasSuffix(name, from) {
+				continue
+			}
+			newName := strings.TrimSuffix(name, from) + to
+			c := convertedFile{From: valid.Dir + name, To: valid.Dir + newName}
+			if !validName.MatchString(newName) {
+				c.Error = "invalid name"
+				conflicts = append(conflicts, c)
+				continue
+			}
+			if _, err := s.Stat(dir + newName); err == nil || !os.IsNotExist(err) {
+				c.Error = "target exists"
+				conflicts = append(conflicts, c)
+				continue
+			}
+			if apply {
+				if err := rename(s, dir+name, dir+newName); err != nil {
+					c.Error = err.Error()
+					conflicts = append(conflicts, c)
+					continue
+				}
+			}
+			renamed = append(renamed, c)
+		}
+		return writeJSON(w, http.StatusOK, struct {
+			DryRun    bool            `json:"dryRun"`
+			Renamed   []convertedFile `json:"renamed"`
+			Conflicts []convertedFile `json:"conflicts"`
+		}{
+			DryRun:    !apply,
+			Renamed:   renamed,
+			Conflicts: conflicts,
+		})
+	}
+}
@@ -0,0 +1,86 @@
+package mngr
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"mime"
+	"net/http"
+)
+
+type csrfCtxKey int
+
+var csrfKey = csrfCtxKey(0)
+
+const (
+	// csrfCookie is the name of the cookie holding the CSRF token.
+	csrfCookie = "mngr_csrf"
+	// CSRFField is the name of the form value carrying the CSRF token.
+	CSRFField = "csrf_token"
+	// CSRFHeader is the header carrying the CSRF token, for scripts.
+	CSRFHeader = "X-CSRF-Token"
+)
+
+// CSRFTokenFromCtx extract the token added by MakeCSRFMiddleware from a
+// context. It return an empty string when there is none.
+func CSRFTokenFromCtx(ctx context.Context) string {
+	token, _ := ctx.Value(csrfKey).(string)
+	return token
+}
+
+// requestCSRFToken return the token sent with r. Multipart forms are
+// streamed by their handlers, they carry the token in the query.
+func requestCSRFToken(r *http.Request) string {
+	if token := r.Header.Get(CSRFHeader); token != "" {
+		return token
+	}
+	ct, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if ct == "multipart/form-data" {
+		return r.URL.Query().Get(CSRFField)
+	}
+	return r.PostFormValue(CSRFField)
+}
+
+// MakeCSRFMiddleware create a middleware protecting the handlers from cross
+// site request forgery. Every browser gets a random token in a cookie, added
+// to the request's context from where it reaches TemplateInfo.CSRFToken.
+// Requests not using a safe method must send the token back, as CSRFField
+// form value or CSRFHeader header, or are answered with 403. Requests with
+// a JSON body are accepted, browsers don't send them across sites without
+// CORS preflight.
+func MakeCSRFMiddleware() Middleware {
+	return func(h Handler) Handler {
+		return HandlerFunc(func(w http.ResponseWriter, r *http.Request) (int, error) {
+			var token string
+			if c, err := r.Cookie(csrfCookie); err == nil && len(c.Value) == 43 {
+				token = c.Value
+			}
+			if !safeMethods[r.Method] {
+				ct, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+				if ct != "application/json" && (token == "" || !secureEqual(requestCSRFToken(r), token)) {
+					w.Header().Set("Content-Type", "text/plain")
+					w.WriteHeader(http.StatusForbidden)
+					w.Write([]byte("forbidden: invalid CSRF token"))
+					return http.StatusForbidden, nil
+				}
+			}
+			if token == "" {
+				b := make([]byte, 32)
+				if _, err := rand.Read(b); err != nil {
+					return 0, err
+				}
+				token = base64.RawURLEncoding.EncodeToString(b)
+				http.SetCookie(w, &http.Cookie{
+					Name:     csrfCookie,
+					Value:    token,
+					Path:     "/",
+					Secure:   r.TLS != nil,
+					HttpOnly: true,
+					SameSite: http.SameSiteLaxMode,
+				})
+			}
+			ctx := context.WithValue(r.Context(), csrfKey, token)
+			return h.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
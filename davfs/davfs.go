@@ -0,0 +1,357 @@
+// Package davfs serves a mngr.Store over WebDAV, so the wiki can be mounted
+// as a network drive and edited with native tools.
+//
+// Files are buffered in memory while opened and written to the store when
+// closed. Deleted files and folders are moved to the trash, like through
+// the web interface. The names the web interface refuses, like those of
+// the hidden '.trash' and '.snapshots' folders, can't be reached. When the
+// request's context holds a mngr.ACL, every path is checked against the
+// role of the user, the files they can't read are not listed.
+package davfs
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/aitva/mngr"
+	"golang.org/x/net/webdav"
+)
+
+var _ webdav.FileSystem = (*FileSystem)(nil)
+
+// FileSystem is a webdav.FileSystem over a mngr.Store.
+type FileSystem struct {
+	store mngr.Store
+}
+
+// New create a FileSystem serving s.
+func New(s mngr.Store) *FileSystem {
+	return &FileSystem{store: s}
+}
+
+// clean return the canonical form of name, without leading slash. Names
+// the web interface can't reach, like the files of the trash, are refused.
+func clean(op, name string) (string, error) {
+	name = strings.TrimPrefix(path.Clean("/"+name), "/")
+	if name != "" && !mngr.ValidPath(name) {
+		return "", &os.PathError{Op: op, Path: name, Err: os.ErrPermission}
+	}
+	return name, nil
+}
+
+// allow return an error when the ACL of ctx, if any, doesn't grant role on
+// name to the user of ctx.
+func allow(ctx context.Context, op, name string, role mngr.Role) error {
+	a, ok := mngr.ACLFromCtx(ctx)
+	if !ok {
+		return nil
+	}
+	user, _ := mngr.UserFromCtx(ctx)
+	if a.Role(user, name) < role {
+		return &os.PathError{Op: op, Path: name, Err: os.ErrPermission}
+	}
+	return nil
+}
+
+// validURL return the ValidURL of the page located at name.
+func validURL(name string) mngr.ValidURL {
+	folder := path.Dir(name)
+	if folder == "." {
+		folder = ""
+	}
+	return mngr.ValidURL{Dir: folder, Value: path.Base(name)}
+}
+
+// Mkdir implements webdav.FileSystem.
+func (fs *FileSystem) Mkdir(ctx context.Context, name string, perm os.FileMode) error {
+	name, err := clean("mkdir", name)
+	if err != nil {
+		return err
+	}
+	if err := allow(ctx, "mkdir", name, mngr.RoleEditor); err != nil {
+		return err
+	}
+	return fs.store.Mkdir(name)
+}
+
+// OpenFile implements webdav.FileSystem.
+func (fs *FileSystem) OpenFile(ctx context.Context, name string, flag int, perm os.FileMode) (webdav.File, error) {
+	name, err := clean("open", name)
+	if err != nil {
+		return nil, err
+	}
+	write := flag&(os.O_WRONLY|os.O_RDWR) != 0
+	role := mngr.RoleViewer
+	if write {
+		role = mngr.RoleEditor
+	}
+	if err := allow(ctx, "open", name, role); err != nil {
+		return nil, err
+	}
+	fi, err := fs.store.Stat(name)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	exists := err == nil
+	if exists && fi.IsDir() {
+		return &dir{ctx: ctx, store: fs.store, name: name, info: fi}, nil
+	}
+	switch {
+	case exists && flag&os.O_CREATE != 0 && flag&os.O_EXCL != 0:
+		return nil, &os.PathError{Op: "open", Path: name, Err: os.ErrExist}
+	case !exists && (!write || flag&os.O_CREATE == 0):
+		return nil, &os.PathError{Op: "open", Path: name, Err: os.ErrNotExist}
+	case !exists:
+		parent, err := fs.store.Stat(path.Dir("/" + name))
+		if err != nil {
+			return nil, err
+		}
+		if !parent.IsDir() {
+			return nil, &os.PathError{Op: "open", Path: name, Err: os.ErrNotExist}
+		}
+	}
+
+	f := &file{store: fs.store, name: name, write: write, dirty: !exists}
+	if exists {
+		f.modTime = fi.ModTime()
+		if !write || flag&os.O_TRUNC == 0 {
+			if f.data, err = fs.store.Read(name); err != nil {
+				return nil, err
+			}
+		} else {
+			f.dirty = true
+		}
+	}
+	if f.dirty {
+		f.modTime = time.Now()
+	}
+	return f, nil
+}
+
+// RemoveAll implements webdav.FileSystem. The file or folder is moved to
+// the trash.
+func (fs *FileSystem) RemoveAll(ctx context.Context, name string) error {
+	name, err := clean("remove", name)
+	if err != nil {
+		return err
+	}
+	if name == "" {
+		return &os.PathError{Op: "remove", Path: "/", Err: os.ErrPermission}
+	}
+	if err := allow(ctx, "remove", name, mngr.RoleEditor); err != nil {
+		return err
+	}
+	return mngr.DeletePath(fs.store, validURL(name))
+}
+
+// Rename implements webdav.FileSystem.
+func (fs *FileSystem) Rename(ctx context.Context, oldName, newName string) error {
+	oldName, err := clean("rename", oldName)
+	if err != nil {
+		return err
+	}
+	if newName, err = clean("rename", newName); err != nil {
+		return err
+	}
+	if oldName == "" || newName == "" {
+		return &os.PathError{Op: "rename", Path: "/", Err: os.ErrPermission}
+	}
+	for _, name := range []string{oldName, newName} {
+		if err := allow(ctx, "rename", name, mngr.RoleEditor); err != nil {
+			return err
+		}
+	}
+	return mngr.MovePath(fs.store, validURL(oldName), newName)
+}
+
+// Stat implements webdav.FileSystem.
+func (fs *FileSystem) Stat(ctx context.Context, name string) (os.FileInfo, error) {
+	name, err := clean("stat", name)
+	if err != nil {
+		return nil, err
+	}
+	if err := allow(ctx, "stat", name, mngr.RoleViewer); err != nil {
+		return nil, err
+	}
+	return fs.store.Stat(name)
+}
+
+// fileInfo describe a file being written.
+type fileInfo struct {
+	name    string
+	size    int64
+	modTime time.Time
+}
+
+func (fi *fileInfo) Name() string       { return fi.name }
+func (fi *fileInfo) Size() int64        { return fi.size }
+func (fi *fileInfo) Mode() os.FileMode  { return 0600 }
+func (fi *fileInfo) ModTime() time.Time { return fi.modTime }
+func (fi *fileInfo) IsDir() bool        { return false }
+func (fi *fileInfo) Sys() interface{}   { return nil }
+
+// file is an opened file, buffered in memory.
+type file struct {
+	store   mngr.Store
+	name    string
+	data    []byte
+	off     int64
+	modTime time.Time
+	write   bool
+	// dirty is set when data must be written to the store on Close.
+	dirty bool
+}
+
+func (f *file) Read(b []byte) (int, error) {
+	if f.off >= int64(len(f.data)) {
+		return 0, io.EOF
+	}
+	n := copy(b, f.data[f.off:])
+	f.off += int64(n)
+	return n, nil
+}
+
+func (f *file) Write(b []byte) (int, error) {
+	if !f.write {
+		return 0, &os.PathError{Op: "write", Path: f.name, Err: os.ErrPermission}
+	}
+	if end := f.off + int64(len(b)); end > int64(len(f.data)) {
+		f.data = append(f.data, make([]byte, end-int64(len(f.data)))...)
+	}
+	n := copy(f.data[f.off:], b)
+	f.off += int64(n)
+	f.dirty = true
+	f.modTime = time.Now()
+	return n, nil
+}
+
+func (f *file) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekCurrent:
+		offset += f.off
+	case io.SeekEnd:
+		offset += int64(len(f.data))
+	}
+	if offset < 0 {
+		return 0, &os.PathError{Op: "seek", Path: f.name, Err: os.ErrInvalid}
+	}
+	f.off = offset
+	return offset, nil
+}
+
+func (f *file) Readdir(count int) ([]os.FileInfo, error) {
+	return nil, &os.PathError{Op: "readdir", Path: f.name, Err: os.ErrInvalid}
+}
+
+func (f *file) Stat() (os.FileInfo, error) {
+	return &fileInfo{name: path.Base("/" + f.name), size: int64(len(f.data)), modTime: f.modTime}, nil
+}
+
+// Close write the file to the store when it was modified.
+func (f *file) Close() error {
+	if !f.dirty {
+		return nil
+	}
+	f.dirty = false
+	return f.store.Write(f.name, f.data)
+}
+
+// dir is an opened folder. Hidden files are not listed, like in the web
+// interface, nor those the user can't read.
+type dir struct {
+	// ctx is the context of the request which opened the folder.
+	ctx   context.Context
+	store mngr.Store
+	name  string
+	info  os.FileInfo
+	// entries is the content left to return from Readdir, once listed.
+	entries []os.FileInfo
+	listed  bool
+}
+
+func (d *dir) Read(b []byte) (int, error) {
+	return 0, &os.PathError{Op: "read", Path: d.name, Err: os.ErrInvalid}
+}
+
+func (d *dir) Write(b []byte) (int, error) {
+	return 0, &os.PathError{Op: "write", Path: d.name, Err: os.ErrInvalid}
+}
+
+func (d *dir) Seek(offset int64, whence int) (int64, error) {
+	if offset == 0 && whence == io.SeekStart {
+		d.entries, d.listed = nil, false
+		return 0, nil
+	}
+	return 0, &os.PathError{Op: "seek", Path: d.name, Err: os.ErrInvalid}
+}
+
+func (d *dir) Readdir(count int) ([]os.FileInfo, error) {
+	if !d.listed {
+		fInfos, err := d.store.List(d.name)
+		if err != nil {
+			return nil, err
+		}
+		for _, fi := range fInfos {
+			if strings.HasPrefix(fi.Name(), ".") {
+				continue
+			}
+			if allow(d.ctx, "readdir", path.Join(d.name, fi.Name()), mngr.RoleViewer) != nil {
+				continue
+			}
+			d.entries = append(d.entries, fi)
+		}
+		d.listed = true
+	}
+	if count <= 0 {
+		entries := d.entries
+		d.entries = nil
+		return entries, nil
+	}
+	if len(d.entries) == 0 {
+		return nil, io.EOF
+	}
+	if count > len(d.entries) {
+		count = len(d.entries)
+	}
+	entries := d.entries[:count]
+	d.entries = d.entries[count:]
+	return entries, nil
+}
+
+func (d *dir) Stat() (os.FileInfo, error) {
+	return d.info, nil
+}
+
+func (d *dir) Close() error {
+	return nil
+}
+
+// modifying lists the WebDAV methods changing the content of the store.
+var modifying = map[string]bool{
+	http.MethodPut:    true,
+	http.MethodDelete: true,
+	"MKCOL":           true,
+	"COPY":            true,
+	"MOVE":            true,
+}
+
+// NewHandler return a WebDAV handler serving s under prefix, with
+// in-memory locks. When changed isn't nil, it is called after every
+// request successfully modifying the store, to refresh indexes for example.
+func NewHandler(s mngr.Store, prefix string, changed func()) http.Handler {
+	return &webdav.Handler{
+		Prefix:     prefix,
+		FileSystem: New(s),
+		LockSystem: webdav.NewMemLS(),
+		Logger: func(r *http.Request, err error) {
+			if err == nil && changed != nil && modifying[r.Method] {
+				changed()
+			}
+		},
+	}
+}
@@ -0,0 +1,166 @@
+package mngr
+
+import (
+	"fmt"
+	"io"
+	"sync"
+	"time"
+)
+
+// SaveDebouncer coalesce the saves of a page happening within a time window.
+// The first save of a path arms a timer, the following saves only replace
+// the pending content, and the latest content is written when the timer
+// fires. Call Flush before exiting so no edit is lost.
+type SaveDebouncer struct {
+	window time.Duration
+	out    io.Writer
+	saved  func(path string)
+
+	mu      sync.Mutex
+	pending map[string]*pendingSave
+	// writing holds the lock of the paths being written, keeping the
+	// writes of a page in order without holding mu.
+	writing map[string]*pathLock
+}
+
+type pendingSave struct {
+	page  *Page
+	timer *time.Timer
+}
+
+// pathLock is the lock of a path, shared by its waiting writers. body is
+// the content flush is writing, if any.
+type pathLock struct {
+	sync.Mutex
+	users int
+	body  []byte
+}
+
+// NewSaveDebouncer create a SaveDebouncer collapsing the saves done within
+// window. Errors happening in delayed writes are reported to out.
+func NewSaveDebouncer(window time.Duration, out io.Writer) *SaveDebouncer {
+	return &SaveDebouncer{
+		window:  window,
+		out:     out,
+		pending: make(map[string]*pendingSave),
+		writing: make(map[string]*pathLock),
+	}
+}
+
+// OnSave set fn to be called with the path of each page written at the end
+// of a window, once the write succeeded.
+func (d *SaveDebouncer) OnSave(fn func(path string)) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.saved = fn
+}
+
+// Save schedule p to be written at the end of the current window.
+func (d *SaveDebouncer) Save(p *Page) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if s, ok := d.pending[p.Path]; ok {
+		s.page = p
+		return
+	}
+	path := p.Path
+	d.pending[path] = &pendingSave{
+		page: p,
+		timer: time.AfterFunc(d.window, func() {
+			if err := d.flush(path); err != nil {
+				fmt.Fprintln(d.out, "debounced save:", path, err)
+			}
+		}),
+	}
+}
+
+// SaveNow drop the pending save of p.Path, if any, and write p immediately.
+func (d *SaveDebouncer) SaveNow(p *Page) error {
+	_, unlock := d.lockPath(p.Path)
+	defer unlock()
+	d.mu.Lock()
+	if s, ok := d.pending[p.Path]; ok {
+		s.timer.Stop()
+		delete(d.pending, p.Path)
+	}
+	d.mu.Unlock()
+	return p.save()
+}
+
+// lockPath wait for the other writes of path to end and return its lock
+// with the function releasing it.
+func (d *SaveDebouncer) lockPath(path string) (*pathLock, func()) {
+	d.mu.Lock()
+	l, ok := d.writing[path]
+	if !ok {
+		l = &pathLock{}
+		d.writing[path] = l
+	}
+	l.users++
+	d.mu.Unlock()
+	l.Lock()
+	return l, func() {
+		d.mu.Lock()
+		l.body = nil
+		if l.users--; l.users == 0 {
+			delete(d.writing, path)
+		}
+		d.mu.Unlock()
+		l.Unlock()
+	}
+}
+
+// pendingBody return the content of the pending save of path, if any.
+func (d *SaveDebouncer) pendingBody(path string) ([]byte, bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if s, ok := d.pending[path]; ok {
+		return s.page.Body, true
+	}
+	if l, ok := d.writing[path]; ok && l.body != nil {
+		return l.body, true
+	}
+	return nil, false
+}
+
+// flush write the pending save of path, if any.
+// The write holds the lock of path so it can't race with SaveNow.
+func (d *SaveDebouncer) flush(path string) error {
+	l, unlock := d.lockPath(path)
+	defer unlock()
+	d.mu.Lock()
+	s, ok := d.pending[path]
+	if ok {
+		s.timer.Stop()
+		delete(d.pending, path)
+		l.body = s.page.Body
+	}
+	saved := d.saved
+	d.mu.Unlock()
+	if !ok {
+		return nil
+	}
+	err := s.page.save()
+	if err == nil && saved != nil {
+		saved(path)
+	}
+	return err
+}
+
+// Flush write every pending save immediately and return the first error.
+func (d *SaveDebouncer) Flush() error {
+	d.mu.Lock()
+	paths := make([]string, 0, len(d.pending))
+	for path := range d.pending {
+		paths = append(paths, path)
+	}
+	d.mu.Unlock()
+
+	var first error
+	for _, path := range paths {
+		if err := d.flush(path); err != nil && first == nil {
+			first = err
+		}
+	}
+	return first
+}
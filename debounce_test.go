@@ -0,0 +1,97 @@
+package mngr
+
+import (
+	"context"
+	"io"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+// boundStore is a ContextStore whose writes fail once its context is done,
+// like the network stores.
+type boundStore struct {
+	Store
+	ctx context.Context
+}
+
+func (s boundStore) WithContext(ctx context.Context) Store {
+	return boundStore{Store: s.Store, ctx: ctx}
+}
+
+func (s boundStore) Write(name string, body []byte) error {
+	if s.ctx != nil {
+		if err := s.ctx.Err(); err != nil {
+			return err
+		}
+	}
+	return s.Store.Write(name, body)
+}
+
+func TestSaveHandlerAutosaveAfterRequest(t *testing.T) {
+	store := DirStore(t.TempDir())
+	d := NewSaveDebouncer(time.Hour, io.Discard)
+	h := MakeSaveHandler(d)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	ctx = NewContextWithStore(ctx, boundStore{Store: store})
+	ctx = NewContextWithValidURL(ctx, ValidURL{Action: "save", Value: "a.md"})
+	form := url.Values{"body": {"draft"}, "autosave": {"1"}}
+	r := httptest.NewRequest("POST", "/save/a.md", strings.NewReader(form.Encode())).WithContext(ctx)
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	if code, err := h(httptest.NewRecorder(), r); code != 204 || err != nil {
+		t.Fatalf("autosave answered %d %v", code, err)
+	}
+	cancel()
+
+	if err := d.Flush(); err != nil {
+		t.Fatalf("Flush() = %v", err)
+	}
+	body, err := store.Read("a.md")
+	if err != nil || string(body) != "draft" {
+		t.Errorf("a.md = %q %v, want the autosaved draft", body, err)
+	}
+}
+
+// slowStore is a Store whose writes wait for release.
+type slowStore struct {
+	Store
+	started, release chan struct{}
+}
+
+func (s slowStore) Write(name string, body []byte) error {
+	s.started <- struct{}{}
+	<-s.release
+	return s.Store.Write(name, body)
+}
+
+func TestSaveDebouncerFlushUnlocked(t *testing.T) {
+	dir := DirStore(t.TempDir())
+	store := slowStore{Store: dir, started: make(chan struct{}), release: make(chan struct{})}
+	d := NewSaveDebouncer(time.Hour, io.Discard)
+	page := func(body string) *Page {
+		return NewPage(store, ValidURL{Action: "save", Value: "a.md"}, []byte(body))
+	}
+	d.Save(page("first"))
+	done := make(chan error)
+	go func() { done <- d.Flush() }()
+	<-store.started
+
+	if body, ok := d.pendingBody("/a.md"); !ok || string(body) != "first" {
+		t.Errorf("pendingBody() during the write = %q %v, want first", body, ok)
+	}
+	d.Save(page("second"))
+	close(store.release)
+	if err := <-done; err != nil {
+		t.Fatalf("Flush() = %v", err)
+	}
+	go func() { <-store.started }()
+	if err := d.Flush(); err != nil {
+		t.Fatalf("Flush() = %v", err)
+	}
+	if body, err := dir.Read("a.md"); err != nil || string(body) != "second" {
+		t.Errorf("a.md = %q %v, want second", body, err)
+	}
+}
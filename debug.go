@@ -0,0 +1,58 @@
+package mngr
+
+import (
+	"expvar"
+	"net/http"
+	"net/http/pprof"
+	"strings"
+)
+
+// debugVars holds the counters published by expvar under "mngr": the pages
+// viewed, the pages saved and the requests failing with a 5xx status.
+var debugVars = expvar.NewMap("mngr")
+
+// MakeDebugCountersMiddleware create a middleware updating the expvar
+// counters served by MakeDebugHandler.
+func MakeDebugCountersMiddleware() Middleware {
+	return func(h Handler) Handler {
+		return HandlerFunc(func(w http.ResponseWriter, r *http.Request) (int, error) {
+			code, err := h.ServeHTTP(w, r)
+			if code >= http.StatusInternalServerError || (code == 0 && err != nil) {
+				debugVars.Add("errors", 1)
+				return code, err
+			}
+			if code >= http.StatusBadRequest {
+				return code, err
+			}
+			switch action := strings.SplitN(strings.TrimPrefix(r.URL.Path, "/"), "/", 2)[0]; {
+			case action == "view":
+				debugVars.Add("pages_served", 1)
+			case action == "save" && r.Method == http.MethodPost:
+				debugVars.Add("saves", 1)
+			}
+			return code, err
+		})
+	}
+}
+
+// MakeDebugHandler return an handler serving the pprof profiles under
+// prefix + "pprof/" and the expvar variables at prefix + "vars", like
+// "/debug/". It exposes the internals of the server: serve it on a private
+// listener or behind admin authentication.
+func MakeDebugHandler(prefix string) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc(prefix+"pprof/", func(w http.ResponseWriter, r *http.Request) {
+		// pprof.Index only finds the profiles under /debug/pprof/.
+		if name := strings.TrimPrefix(r.URL.Path, prefix+"pprof/"); name != "" {
+			pprof.Handler(name).ServeHTTP(w, r)
+			return
+		}
+		pprof.Index(w, r)
+	})
+	mux.HandleFunc(prefix+"pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc(prefix+"pprof/profile", pprof.Profile)
+	mux.HandleFunc(prefix+"pprof/symbol", pprof.Symbol)
+	mux.HandleFunc(prefix+"pprof/trace", pprof.Trace)
+	mux.Handle(prefix+"vars", expvar.Handler())
+	return mux
+}
@@ -0,0 +1,182 @@
+package mngr
+
+import (
+	"net/http"
+	"strings"
+)
+
+// DiffLine is a line of a unified diff. Op is " " for a line found in both
+// versions, "-" for a removed line and "+" for an added one.
+type DiffLine struct {
+	Op   string
+	Text string
+}
+
+// DiffRow is a row of a side by side diff. Left or Right is nil when the
+// line only exists on the other side.
+type DiffRow struct {
+	Changed     bool
+	Left, Right *string
+}
+
+// diffLines compute the shortest edit script turning a into b, using the
+// Myers algorithm.
+func diffLines(a, b []string) []DiffLine {
+	n, m := len(a), len(b)
+	max := n + m
+	v := make([]int, 2*max+2)
+	var trace [][]int
+search:
+	for d := 0; d <= max; d++ {
+		trace = append(trace, append([]int(nil), v...))
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && v[max+k-1] < v[max+k+1]) {
+				x = v[max+k+1]
+			} else {
+				x = v[max+k-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+			v[max+k] = x
+			if x >= n && y >= m {
+				break search
+			}
+		}
+	}
+
+	// Walk the trace backward to recover the edits, from the end.
+	var lines []DiffLine
+	x, y := n, m
+	for d := len(trace) - 1; d >= 0; d-- {
+		v := trace[d]
+		k := x - y
+		prevK := k - 1
+		if k == -d || (k != d && v[max+k-1] < v[max+k+1]) {
+			prevK = k + 1
+		}
+		prevX := v[max+prevK]
+		prevY := prevX - prevK
+		for x > prevX && y > prevY {
+			lines = append(lines, DiffLine{Op: " ", Text: a[x-1]})
+			x--
+			y--
+		}
+		if d == 0 {
+			break
+		}
+		if x == prevX {
+			lines = append(lines, DiffLine{Op: "+", Text: b[y-1]})
+		} else {
+			lines = append(lines, DiffLine{Op: "-", Text: a[x-1]})
+		}
+		x, y = prevX, prevY
+	}
+	for i, j := 0, len(lines)-1; i < j; i, j = i+1, j-1 {
+		lines[i], lines[j] = lines[j], lines[i]
+	}
+	return lines
+}
+
+// sideBySide pair the removed and added lines of a unified diff.
+func sideBySide(lines []DiffLine) []DiffRow {
+	var rows []DiffRow
+	for i := 0; i < len(lines); {
+		if lines[i].Op == " " {
+			rows = append(rows, DiffRow{Left: &lines[i].Text, Right: &lines[i].Text})
+			i++
+			continue
+		}
+		var removed, added []*string
+		for ; i < len(lines) && lines[i].Op == "-"; i++ {
+			removed = append(removed, &lines[i].Text)
+		}
+		for ; i < len(lines) && lines[i].Op == "+"; i++ {
+			added = append(added, &lines[i].Text)
+		}
+		for j := 0; j < len(removed) || j < len(added); j++ {
+			row := DiffRow{Changed: true}
+			if j < len(removed) {
+				row.Left = removed[j]
+			}
+			if j < len(added) {
+				row.Right = added[j]
+			}
+			rows = append(rows, row)
+		}
+	}
+	return rows
+}
+
+// splitLines cut a body in lines, without their line ending.
+func splitLines(body []byte) []string {
+	s := strings.TrimSuffix(strings.Replace(string(body), "\r\n", "\n", -1), "\n")
+	if s == "" {
+		return nil
+	}
+	return strings.Split(s, "\n")
+}
+
+// DiffHandler is an handler rendering, with diff.html, the changes made
+// to a page between the revisions given by the 'from' and 'to' query
+// values. An empty 'to' compare with the current content. The diff is
+// unified unless the 'mode' value is "side".
+func DiffHandler(w http.ResponseWriter, r *http.Request) (int, error) {
+	valid, _ := ValidURLFromCtx(r.Context())
+	s, _ := StoreFromCtx(r.Context())
+	versioned, ok := s.(Versioned)
+	if !ok {
+		return writeNotVersioned(w)
+	}
+	q := r.URL.Query()
+	from, to := q.Get("from"), q.Get("to")
+	if from == "" {
+		w.Header().Set("Content-Type", "text/plain")
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte("bad request: missing revision"))
+		return http.StatusBadRequest, nil
+	}
+	name := PagePathFromValidURL(valid)
+	a, err := versioned.ReadRevision(name, from)
+	if err != nil {
+		return 0, err
+	}
+	var b []byte
+	if to == "" {
+		b, err = s.Read(name)
+	} else {
+		b, err = versioned.ReadRevision(name, to)
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	p := &struct {
+		TemplateInfo
+		Path     string
+		From, To string
+		Binary   bool
+		Side     bool
+		Lines    []DiffLine
+		Rows     []DiffRow
+	}{
+		TemplateInfo: newTemplateInfo(r, valid),
+		Path:         name,
+		From:         from,
+		To:           to,
+		Binary:       !isText(a) || !isText(b),
+		Side:         q.Get("mode") == "side",
+	}
+	if !p.Binary {
+		p.Lines = diffLines(splitLines(a), splitLines(b))
+		if p.Side {
+			p.Rows = sideBySide(p.Lines)
+		}
+	}
+	t, _ := TemplateFromCtx(r.Context())
+	err = t.ExecuteTemplate(w, "diff.html", p)
+	return 200, err
+}
@@ -0,0 +1,53 @@
+package mngr
+
+import (
+	"io"
+	"mime"
+	"net/http"
+	"path"
+)
+
+// DownloadHandler is an handler use to download the raw content of a file.
+// The Content-Type is guessed from the file extension, or from the content
+// when the extension is unknown, and the file is sent as an attachment.
+// Conditional and range requests are answered with the ETag of the content
+// and the modification time of the file. The file is streamed when the
+// Store is an Opener.
+func DownloadHandler(w http.ResponseWriter, r *http.Request) (int, error) {
+	valid, _ := ValidURLFromCtx(r.Context())
+	s, _ := StoreFromCtx(r.Context())
+	name := PagePathFromValidURL(valid)
+	fi, err := s.Stat(name)
+	if err != nil {
+		return 0, err
+	}
+	if fi.IsDir() {
+		w.Header().Set("Content-Type", "text/plain")
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte("bad request"))
+		return http.StatusBadRequest, nil
+	}
+
+	f, err := openFile(s, name)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+	etag, err := readerETag(f)
+	if err != nil {
+		return 0, err
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return 0, err
+	}
+	if ctype := mime.TypeByExtension(path.Ext(valid.Value)); ctype != "" {
+		w.Header().Set("Content-Type", ctype)
+	}
+	disposition := mime.FormatMediaType("attachment", map[string]string{"filename": valid.Value})
+	w.Header().Set("Content-Disposition", disposition)
+	// ServeContent answers the conditional requests with the ETag.
+	w.Header().Set("ETag", etag)
+	sw := &statusWriter{ResponseWriter: w}
+	http.ServeContent(sw, r, valid.Value, fi.ModTime(), f)
+	return sw.status, nil
+}
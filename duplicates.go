@@ -0,0 +1,80 @@
+package mngr
+
+import (
+	"context"
+	"net/http"
+)
+
+// DuplicateGroup is a set of files sharing the same content.
+type DuplicateGroup struct {
+	Hash  string   `json:"hash"`
+	Size  int64    `json:"size"`
+	Paths []string `json:"paths"`
+}
+
+// findDuplicates return the groups of identical files located under
+// dir in s, among the readable ones, see walkFiles. Files are first
+// bucketed by size and only the files sharing their size with another one
+// are hashed.
+func findDuplicates(ctx context.Context, s Store, dir string, readable func(p string) bool) ([]DuplicateGroup, error) {
+	var sizes []int64
+	bySize := make(map[int64][]string)
+	err := walkFiles(ctx, s, dir, readable, func(path string) error {
+		fi, err := s.Stat(path)
+		if err != nil {
+			return err
+		}
+		size := fi.Size()
+		if _, ok := bySize[size]; !ok {
+			sizes = append(sizes, size)
+		}
+		bySize[size] = append(bySize[size], path)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	groups := []DuplicateGroup{}
+	for _, size := range sizes {
+		paths := bySize[size]
+		if len(paths) < 2 {
+			continue
+		}
+		var hashes []string
+		byHash := make(map[string][]string)
+		for _, path := range paths {
+			if err := ctx.Err(); err != nil {
+				return nil, err
+			}
+			hash, err := hashFile(s, path)
+			if err != nil {
+				return nil, err
+			}
+			if _, ok := byHash[hash]; !ok {
+				hashes = append(hashes, hash)
+			}
+			byHash[hash] = append(byHash[hash], path)
+		}
+		for _, hash := range hashes {
+			if len(byHash[hash]) > 1 {
+				groups = append(groups, DuplicateGroup{Hash: hash, Size: size, Paths: byHash[hash]})
+			}
+		}
+	}
+	return groups, nil
+}
+
+// MakeDuplicatesHandler return an handler listing, as JSON, the groups of
+// files with identical content located under the requested folder, among
+// those the ACL of the request, if any, lets the user read.
+func MakeDuplicatesHandler(s Store) ContextHandlerFunc {
+	return func(ctx context.Context, w http.ResponseWriter, r *http.Request) (int, error) {
+		valid, _ := ValidURLFromCtx(ctx)
+		groups, err := findDuplicates(ctx, s, valid.Dir, readFilter(ctx))
+		if err != nil {
+			return 0, err
+		}
+		return writeJSON(w, http.StatusOK, groups)
+	}
+}
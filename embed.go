@@ -0,0 +1,88 @@
+package mngr
+
+import (
+	"embed"
+	"errors"
+	"io/fs"
+	"sort"
+)
+
+//go:embed tmpl static locale
+var embedded embed.FS
+
+var (
+	// DefaultTemplates are the templates shipped with mngr, see
+	// MakeFSTemplateMiddleware.
+	DefaultTemplates = mustSub(embedded, "tmpl")
+	// DefaultStatic are the static files shipped with mngr, see
+	// MakeFSStaticHandler.
+	DefaultStatic = mustSub(embedded, "static")
+	// DefaultLocales are the message catalogs shipped with mngr, see
+	// LoadCatalog.
+	DefaultLocales = mustSub(embedded, "locale")
+	// DefaultCatalog translates the default templates, it is loaded from
+	// DefaultLocales.
+	DefaultCatalog = mustCatalog(DefaultLocales)
+)
+
+// mustSub return the sub tree dir of fsys.
+func mustSub(fsys fs.FS, dir string) fs.FS {
+	sub, err := fs.Sub(fsys, dir)
+	if err != nil {
+		panic(err)
+	}
+	return sub
+}
+
+// mustCatalog return the catalog of fsys.
+func mustCatalog(fsys fs.FS) *Catalog {
+	c, err := LoadCatalog(fsys)
+	if err != nil {
+		panic(err)
+	}
+	return c
+}
+
+// overlayFS is the fs.FS returned by OverlayFS.
+type overlayFS struct {
+	upper, lower fs.FS
+}
+
+// OverlayFS return a file system serving the files of upper, and those of
+// lower which are missing from upper. Its folders list the files of both.
+// A missing upper folder, like os.DirFS of a path which doesn't exist, is
+// empty.
+func OverlayFS(upper, lower fs.FS) fs.FS {
+	return overlayFS{upper: upper, lower: lower}
+}
+
+func (o overlayFS) Open(name string) (fs.File, error) {
+	f, err := o.upper.Open(name)
+	if err == nil {
+		return f, nil
+	}
+	if !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+	return o.lower.Open(name)
+}
+
+func (o overlayFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	upper, uerr := fs.ReadDir(o.upper, name)
+	lower, lerr := fs.ReadDir(o.lower, name)
+	if uerr != nil && lerr != nil {
+		return nil, uerr
+	}
+	seen := make(map[string]bool, len(upper))
+	entries := upper
+	for _, e := range upper {
+		seen[e.Name()] = true
+	}
+	for _, e := range lower {
+		if !seen[e.Name()] {
+			entries = append(entries, e)
+		}
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Name() < entries[j].Name() })
+	return entries, nil
+}
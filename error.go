@@ -0,0 +1,252 @@
+package mngr
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// ErrorStatusFunc maps an error returned by an Handler to an HTTP status code.
+// It returns 0 when it doesn't know the error, the caller then falls back
+// to http.StatusInternalServerError.
+type ErrorStatusFunc func(error) int
+
+// HTTPError is an error carrying the status code of the response, like
+// ErrNotFound. Handlers return it, or wrap it with fmt.Errorf and %w, to
+// choose the code of their error.
+type HTTPError struct {
+	Code int
+	// Err is the cause of the error, if any.
+	Err error
+}
+
+// NewHTTPError create an HTTPError of code caused by err.
+func NewHTTPError(code int, err error) *HTTPError {
+	return &HTTPError{Code: code, Err: err}
+}
+
+// Error return the message of the cause of e, or the text of its code.
+func (e *HTTPError) Error() string {
+	if e.Err != nil {
+		return e.Err.Error()
+	}
+	return strings.ToLower(http.StatusText(e.Code))
+}
+
+// Unwrap return the cause of e.
+func (e *HTTPError) Unwrap() error {
+	return e.Err
+}
+
+// Is report whether target is an HTTPError of the code of e without cause,
+// like ErrNotFound, so errors.Is(NewHTTPError(404, err), ErrNotFound) holds.
+func (e *HTTPError) Is(target error) bool {
+	t, ok := target.(*HTTPError)
+	return ok && t.Err == nil && t.Code == e.Code
+}
+
+// The errors of the common status codes.
+var (
+	ErrBadRequest       = &HTTPError{Code: http.StatusBadRequest}
+	ErrUnauthorized     = &HTTPError{Code: http.StatusUnauthorized}
+	ErrForbidden        = &HTTPError{Code: http.StatusForbidden}
+	ErrNotFound         = &HTTPError{Code: http.StatusNotFound}
+	ErrMethodNotAllowed = &HTTPError{Code: http.StatusMethodNotAllowed}
+	ErrConflict         = &HTTPError{Code: http.StatusConflict}
+	ErrTooLarge         = &HTTPError{Code: http.StatusRequestEntityTooLarge}
+)
+
+// DefaultErrorStatus maps the HTTPError, the common os errors and the
+// context.DeadlineExceeded of MakeTimeoutMiddleware to an HTTP status code.
+func DefaultErrorStatus(err error) int {
+	var herr *HTTPError
+	switch {
+	case errors.As(err, &herr):
+		return herr.Code
+	case errors.Is(err, fs.ErrNotExist):
+		return http.StatusNotFound
+	case errors.Is(err, fs.ErrPermission):
+		return http.StatusForbidden
+	case errors.Is(err, fs.ErrExist):
+		return http.StatusConflict
+	case errors.Is(err, context.DeadlineExceeded):
+		return http.StatusServiceUnavailable
+	}
+	return 0
+}
+
+// writeError answer r with the text of err and the code, or 500 when code
+// is 0.
+func writeError(w http.ResponseWriter, r *http.Request, code int, err error) {
+	if code == 0 {
+		code = http.StatusInternalServerError
+	}
+	w.Header().Set("Content-Type", "text/plain")
+	w.WriteHeader(code)
+	fmt.Fprintln(w, err)
+	writeRequestID(w, r)
+}
+
+// errorStatus return the code of the HTTPError in err, if any, or the code
+// status choose for err, 500 when it is 0.
+func errorStatus(status ErrorStatusFunc, err error) int {
+	var herr *HTTPError
+	if errors.As(err, &herr) {
+		return herr.Code
+	}
+	code := 0
+	if status != nil {
+		code = status(err)
+	}
+	if code == 0 {
+		code = http.StatusInternalServerError
+	}
+	return code
+}
+
+// MakeErrorMiddleware create an error handling middleware.
+// When an Handler return 0 and an error, the middleware uses the code of the
+// HTTPError in it, or consults status to choose the response code, write the
+// error to the client and return the chosen code. A nil status behave like a
+// function always returning 0.
+func MakeErrorMiddleware(status ErrorStatusFunc) Middleware {
+	return func(h Handler) Handler {
+		return HandlerFunc(func(w http.ResponseWriter, r *http.Request) (int, error) {
+			code, err := h.ServeHTTP(w, r)
+			if code != 0 || err == nil {
+				return code, err
+			}
+			code = errorStatus(status, err)
+			writeError(w, r, code, err)
+			return code, err
+		})
+	}
+}
+
+// ErrorPage is the data of the error templates, see
+// MakeErrorPageMiddleware.
+type ErrorPage struct {
+	TemplateInfo
+	// Code is the status code of the response.
+	Code int
+	// Status is the text of the status code, like "Not Found".
+	Status string
+	// Error is the error returned by the handler, empty when the handler
+	// wrote the error itself.
+	Error string
+	// RequestID is the ID of the request, see RequestIDFromCtx.
+	RequestID string
+}
+
+// errorTemplate return the name of the template of the status code.
+func errorTemplate(code int) string {
+	return strconv.Itoa(code) + ".html"
+}
+
+// errorPageWriter is an http.ResponseWriter holding back the text/plain
+// error responses which have a template, to render it in their place.
+type errorPageWriter struct {
+	http.ResponseWriter
+	t *Templates
+	// code is the status of the response held back.
+	code  int
+	wrote bool
+}
+
+func (w *errorPageWriter) WriteHeader(code int) {
+	if !w.wrote && code >= http.StatusBadRequest && w.t.has(errorTemplate(code)) &&
+		strings.HasPrefix(w.Header().Get("Content-Type"), "text/plain") {
+		w.code = code
+	} else {
+		w.ResponseWriter.WriteHeader(code)
+	}
+	w.wrote = true
+}
+
+func (w *errorPageWriter) Write(b []byte) (int, error) {
+	if !w.wrote {
+		w.WriteHeader(http.StatusOK)
+	}
+	if w.code != 0 {
+		return len(b), nil
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+// Unwrap return the wrapped http.ResponseWriter, for http.ResponseController.
+func (w *errorPageWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// renderErrorPage write the response of code rendered with its template
+// to w. It report false, without writing anything, when the template is
+// missing or fail.
+func renderErrorPage(w http.ResponseWriter, r *http.Request, t *Templates, code int, err error) bool {
+	name := errorTemplate(code)
+	if !t.has(name) {
+		return false
+	}
+	valid, _ := ValidURLFromCtx(r.Context())
+	p := &ErrorPage{
+		TemplateInfo: newTemplateInfo(r, valid),
+		Code:         code,
+		Status:       http.StatusText(code),
+		RequestID:    RequestIDFromCtx(r.Context()),
+	}
+	p.Action = "error"
+	p.Title = p.Status
+	if err != nil {
+		p.Error = err.Error()
+	}
+	var buf bytes.Buffer
+	if t.ExecuteTemplate(&buf, name, p) != nil {
+		return false
+	}
+	w.Header().Del("Content-Length")
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(code)
+	buf.WriteTo(w)
+	return true
+}
+
+// MakeErrorPageMiddleware create a middleware rendering the errors with the
+// template named after their status code, like '404.html' or '500.html',
+// when the templates of the request's context have one. It must be plugged
+// after a template middleware. The errors are those returned by the Handler,
+// whose code is chosen like MakeErrorMiddleware does with status, and the
+// text/plain responses of 400 and above written by the Handler. The other
+// errors are left to the following middleware, which answer them with text.
+func MakeErrorPageMiddleware(status ErrorStatusFunc) Middleware {
+	return func(h Handler) Handler {
+		return HandlerFunc(func(w http.ResponseWriter, r *http.Request) (int, error) {
+			t, ok := TemplateFromCtx(r.Context())
+			if !ok {
+				return h.ServeHTTP(w, r)
+			}
+			ew := &errorPageWriter{ResponseWriter: w, t: t}
+			code, err := h.ServeHTTP(ew, r)
+			if ew.code != 0 {
+				if !renderErrorPage(w, r, t, ew.code, err) {
+					w.Header().Set("Content-Type", "text/plain")
+					w.WriteHeader(ew.code)
+					fmt.Fprintln(w, http.StatusText(ew.code))
+					writeRequestID(w, r)
+				}
+				return ew.code, err
+			}
+			if code != 0 || err == nil || ew.wrote {
+				return code, err
+			}
+			code = errorStatus(status, err)
+			if !renderErrorPage(w, r, t, code, err) {
+				return 0, err
+			}
+			return code, err
+		})
+	}
+}
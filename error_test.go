@@ -0,0 +1,65 @@
+package mngr
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestDefaultErrorStatus(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want int
+	}{
+		{"not exist", &fs.PathError{Op: "open", Path: "a.md", Err: fs.ErrNotExist}, http.StatusNotFound},
+		{"permission", fmt.Errorf("write: %w", fs.ErrPermission), http.StatusForbidden},
+		{"exist", fs.ErrExist, http.StatusConflict},
+		{"deadline", context.DeadlineExceeded, http.StatusServiceUnavailable},
+		{"http error", fmt.Errorf("save: %w", NewHTTPError(http.StatusTeapot, errors.New("tea"))), http.StatusTeapot},
+		{"other", errors.New("boom"), 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := DefaultErrorStatus(tt.err); got != tt.want {
+				t.Errorf("DefaultErrorStatus(%v) = %d, want %d", tt.err, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMakeErrorMiddleware(t *testing.T) {
+	tests := []struct {
+		name   string
+		status ErrorStatusFunc
+		err    error
+		want   int
+	}{
+		{"not exist", DefaultErrorStatus, fs.ErrNotExist, http.StatusNotFound},
+		{"permission", DefaultErrorStatus, fs.ErrPermission, http.StatusForbidden},
+		{"exist", DefaultErrorStatus, fs.ErrExist, http.StatusConflict},
+		{"wrapped http error", DefaultErrorStatus, fmt.Errorf("move: %w", ErrBadRequest), http.StatusBadRequest},
+		{"http error first", func(error) int { return http.StatusTeapot }, fmt.Errorf("move: %w", ErrNotFound), http.StatusNotFound},
+		{"fallback", DefaultErrorStatus, errors.New("boom"), http.StatusInternalServerError},
+		{"nil status", nil, fs.ErrNotExist, http.StatusInternalServerError},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := MakeErrorMiddleware(tt.status)(HandlerFunc(func(w http.ResponseWriter, r *http.Request) (int, error) {
+				return 0, tt.err
+			}))
+			w := httptest.NewRecorder()
+			code, err := h.ServeHTTP(w, httptest.NewRequest("GET", "/view/a.md", nil))
+			if code != tt.want || w.Code != tt.want {
+				t.Errorf("returned %d and wrote %d, want %d", code, w.Code, tt.want)
+			}
+			if err != tt.err {
+				t.Errorf("returned error %v, want %v", err, tt.err)
+			}
+		})
+	}
+}
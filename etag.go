@@ -0,0 +1,92 @@
+package mngr
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// etagSeed is mixed in the entity tags of the rendered pages, so they
+// change when the server, and maybe its templates, is restarted.
+var etagSeed = func() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}()
+
+// contentETag return the strong entity tag of body.
+func contentETag(body []byte) string {
+	sum := sha256.Sum256(body)
+	return `"` + hex.EncodeToString(sum[:16]) + `"`
+}
+
+// readerETag return the strong entity tag of the content of r, like
+// contentETag.
+func readerETag(r io.Reader) (string, error) {
+	h := sha256.New()
+	if _, err := io.Copy(h, r); err != nil {
+		return "", err
+	}
+	return `"` + hex.EncodeToString(h.Sum(nil)[:16]) + `"`, nil
+}
+
+// viewETag return the weak entity tag of p rendered by the view handler
+// with t: it covers the content of the page, the version of the templates
+// and the values of the request displayed with it, set by fromRequest.
+func viewETag(p *Page, t *Templates) string {
+	h := sha256.New()
+	h.Write(p.Body)
+	for _, s := range []string{etagSeed, t.version, p.Revision, p.User, p.Nonce, p.CSRFToken, p.Base, p.Lang} {
+		h.Write([]byte{0})
+		h.Write([]byte(s))
+	}
+	if p.ReadOnly {
+		h.Write([]byte{1})
+	}
+	return `W/"` + hex.EncodeToString(h.Sum(nil)[:16]) + `"`
+}
+
+// etagMatch report whether the If-None-Match header of r holds etag, with
+// the weak comparison.
+func etagMatch(r *http.Request, etag string) bool {
+	etag = strings.TrimPrefix(etag, "W/")
+	for _, tag := range strings.Split(r.Header.Get("If-None-Match"), ",") {
+		tag = strings.TrimSpace(tag)
+		if tag == "*" || strings.TrimPrefix(tag, "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// checkNotModified set the ETag and, when modtime isn't zero, the
+// Last-Modified headers of the response to r. When the conditional headers
+// of a GET or HEAD request show the client has this version, it answer 304
+// and return true. If-Modified-Since is ignored when If-None-Match is set.
+func checkNotModified(w http.ResponseWriter, r *http.Request, etag string, modtime time.Time) bool {
+	w.Header().Set("ETag", etag)
+	if !modtime.IsZero() {
+		w.Header().Set("Last-Modified", modtime.UTC().Format(http.TimeFormat))
+	}
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		return false
+	}
+	match := false
+	if r.Header.Get("If-None-Match") != "" {
+		match = etagMatch(r, etag)
+	} else if since, err := http.ParseTime(r.Header.Get("If-Modified-Since")); err == nil && !modtime.IsZero() {
+		match = !modtime.Truncate(time.Second).After(since)
+	}
+	if !match {
+		return false
+	}
+	h := w.Header()
+	h.Del("Content-Type")
+	h.Del("Content-Length")
+	w.WriteHeader(http.StatusNotModified)
+	return true
+}
@@ -0,0 +1,35 @@
+package mngr
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+)
+
+// MakeExportHandler return an handler which stream every page located under
+// the requested folder as a single Markdown document. Each page is preceded
+// by a header containing its path, non text files are listed but their
+// content is not included. Up to workers files are read in parallel. The
+// pages the ACL of the request, if any, doesn't let the user read are left
+// out.
+func MakeExportHandler(s Store, workers int) ContextHandlerFunc {
+	return func(ctx context.Context, w http.ResponseWriter, r *http.Request) (int, error) {
+		valid, _ := ValidURLFromCtx(ctx)
+		w.Header().Set("Content-Type", "text/markdown; charset=utf-8")
+		w.Header().Set("Content-Disposition", `attachment; filename="export.md"`)
+		w.WriteHeader(http.StatusOK)
+		err := walkPages(ctx, s, valid.Dir, readFilter(ctx), workers, func(path string, body []byte) error {
+			fmt.Fprintf(w, "\n---\n\n## %s\n\n", path)
+			if !isText(body) {
+				_, err := fmt.Fprintln(w, "_Binary file, content not included._")
+				return err
+			}
+			_, err := w.Write(body)
+			if err == nil && len(body) > 0 && body[len(body)-1] != '\n' {
+				_, err = fmt.Fprintln(w)
+			}
+			return err
+		})
+		return 200, err
+	}
+}
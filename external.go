@@ -0,0 +1,51 @@
+package mngr
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// externalLinks return, for every text page located under dir in s,
+// the distinct http and https URLs it links to. When domain is not empty,
+// only the URLs of this domain or its sub-domains are kept. Only the
+// readable pages are read, see walkFiles.
+func externalLinks(ctx context.Context, s Store, dir, domain string, readable func(p string) bool, workers int) (map[string][]string, error) {
+	domain = strings.ToLower(domain)
+	pages := make(map[string][]string)
+	err := walkPages(ctx, s, dir, readable, workers, func(path string, body []byte) error {
+		if !isText(body) {
+			return nil
+		}
+		seen := make(map[string]bool)
+		for _, l := range ExtractLinks(body) {
+			u, err := url.Parse(l.Target)
+			if err != nil || (u.Scheme != "http" && u.Scheme != "https") || seen[l.Target] {
+				continue
+			}
+			host := strings.ToLower(u.Hostname())
+			if domain != "" && host != domain && !strings.HasSuffix(host, "."+domain) {
+				continue
+			}
+			seen[l.Target] = true
+			pages[path] = append(pages[path], l.Target)
+		}
+		return nil
+	})
+	return pages, err
+}
+
+// MakeExternalLinksHandler return an handler mapping, as JSON, the pages
+// located under the requested folder to the external URLs they link to.
+// The 'domain' query value restricts the URLs to a domain.
+func MakeExternalLinksHandler(s Store, workers int) ContextHandlerFunc {
+	return func(ctx context.Context, w http.ResponseWriter, r *http.Request) (int, error) {
+		valid, _ := ValidURLFromCtx(ctx)
+		pages, err := externalLinks(ctx, s, valid.Dir, r.URL.Query().Get("domain"), readFilter(ctx), workers)
+		if err != nil {
+			return 0, err
+		}
+		return writeJSON(w, http.StatusOK, pages)
+	}
+}
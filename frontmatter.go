@@ -0,0 +1,139 @@
+package mngr
+
+import (
+	"bufio"
+	"bytes"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// FrontMatter contains the key/value pairs found in a page front matter.
+type FrontMatter map[string]string
+
+// frontMatterDelims associate an Hugo front matter delimiter with
+// the separator used between keys and values.
+var frontMatterDelims = map[string]string{
+	"---": ":",
+	"+++": "=",
+}
+
+// splitFrontMatter separate the front matter of body from its content.
+// Hugo's YAML ('---') and TOML ('+++') delimiters are supported. When body
+// has no front matter, or when the front matter is never closed, fm is nil
+// and content is body.
+func splitFrontMatter(body []byte) (fm, content []byte) {
+	i := bytes.IndexByte(body, '\n')
+	if i == -1 {
+		return nil, body
+	}
+	delim := string(bytes.TrimSpace(body[:i]))
+	if _, ok := frontMatterDelims[delim]; !ok {
+		return nil, body
+	}
+	rest := body[i+1:]
+	for off := 0; off < len(rest); {
+		j := bytes.IndexByte(rest[off:], '\n')
+		line := rest[off:]
+		next := len(rest)
+		if j != -1 {
+			line = rest[off : off+j]
+			next = off + j + 1
+		}
+		if string(bytes.TrimSpace(line)) == delim {
+			return body[:i+1+next], rest[next:]
+		}
+		off = next
+	}
+	return nil, body
+}
+
+// ParseFrontMatter extract the front matter of a page.
+// Only flat 'key: value' (YAML) and 'key = value' (TOML) pairs are
+// understood, nested values and lists are ignored. Surrounding quotes are
+// removed from values. The returned map is nil when body has no front matter.
+func ParseFrontMatter(body []byte) FrontMatter {
+	raw, _ := splitFrontMatter(body)
+	if raw == nil {
+		return nil
+	}
+	s := bufio.NewScanner(bytes.NewReader(raw))
+	s.Scan()
+	sep := frontMatterDelims[strings.TrimSpace(s.Text())]
+	fm := make(FrontMatter)
+	for s.Scan() {
+		line := s.Text()
+		if len(line) == 0 || line[0] == ' ' || line[0] == '\t' || line[0] == '#' {
+			continue
+		}
+		i := strings.Index(line, sep)
+		if i == -1 {
+			continue
+		}
+		key := strings.TrimSpace(line[:i])
+		value := strings.TrimSpace(line[i+1:])
+		if len(value) >= 2 && (value[0] == '"' || value[0] == '\'') && value[len(value)-1] == value[0] {
+			value = value[1 : len(value)-1]
+		}
+		if key != "" && value != "" {
+			fm[key] = value
+		}
+	}
+	return fm
+}
+
+// atxHeading match a Markdown heading and capture its text.
+var atxHeading = regexp.MustCompile(`^#{1,6}\s+(.*?)(?:\s+#+)?\s*$`)
+
+// isText report whether data looks like text rather than binary content.
+func isText(data []byte) bool {
+	return strings.HasPrefix(http.DetectContentType(data), "text/")
+}
+
+// PageTitle derive a human title from the content of a page.
+// It use the front matter 'title' first, then the first Markdown heading and
+// finally fallback to an empty string.
+func PageTitle(body []byte) string {
+	if title := ParseFrontMatter(body)["title"]; title != "" {
+		return title
+	}
+	_, content := splitFrontMatter(body)
+	s := bufio.NewScanner(bytes.NewReader(content))
+	inCode := false
+	for s.Scan() {
+		line := strings.TrimSpace(s.Text())
+		if strings.HasPrefix(line, "```") || strings.HasPrefix(line, "~~~") {
+			inCode = !inCode
+			continue
+		}
+		if inCode {
+			continue
+		}
+		if m := atxHeading.FindStringSubmatch(line); m != nil {
+			return m[1]
+		}
+	}
+	return ""
+}
+
+// dateLayouts lists the date formats accepted in front matter, like Hugo.
+var dateLayouts = []string{
+	time.RFC3339,
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04:05",
+	"2006-01-02",
+}
+
+// ParseDate parse a front matter date. Dates without time zone are in UTC.
+func ParseDate(s string) (time.Time, error) {
+	var err error
+	for _, layout := range dateLayouts {
+		var t time.Time
+		t, err = time.Parse(layout, s)
+		if err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, err
+}
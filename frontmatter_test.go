@@ -0,0 +1,61 @@
+package mngr
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestPageTitle(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		want string
+	}{
+		{"front matter", "---\ntitle: Release notes\n---\n# Changes\n", "Release notes"},
+		{"heading", "Some text.\n\n## Getting started ##\n\n# Later\n", "Getting started"},
+		{"heading after front matter", "---\nauthor: ana\n---\n# Install\n", "Install"},
+		{"heading in code", "```\n# not a title\n```\n# Usage\n", "Usage"},
+		{"no title", "just text\n", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := PageTitle([]byte(tt.body)); got != tt.want {
+				t.Errorf("PageTitle(%q) = %q, want %q", tt.body, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFileEntriesTitles(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"front.md":   "---\ntitle: From front matter\n---\n# Heading\n",
+		"heading.md": "# From heading\n",
+		"plain.md":   "no heading\n",
+		"image.png":  "\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR# Not a title\n",
+	}
+	for name, body := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(body), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want := map[string]string{
+		"front.md":   "From front matter",
+		"heading.md": "From heading",
+		"plain.md":   "plain.md",
+		"image.png":  "image.png",
+	}
+	names := []string{"front.md", "heading.md", "plain.md", "image.png"}
+
+	for _, e := range fileEntries(DirStore(dir), "/", names, true) {
+		if e.Title != want[e.Name] {
+			t.Errorf("title of %s = %q, want %q", e.Name, e.Title, want[e.Name])
+		}
+	}
+	for _, e := range fileEntries(DirStore(dir), "/", names, false) {
+		if e.Title != e.Name {
+			t.Errorf("title of %s without titles = %q", e.Name, e.Title)
+		}
+	}
+}
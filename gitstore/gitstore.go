@@ -0,0 +1,326 @@
+// Package gitstore implements a mngr.Store keeping the pages in a git
+// repository. Every change is recorded by a commit, giving the wiki an
+// automatic history.
+package gitstore
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"path"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aitva/mngr"
+)
+
+var (
+	_ mngr.Store     = (*Store)(nil)
+	_ mngr.Renamer   = (*Store)(nil)
+	_ mngr.Creator   = (*Store)(nil)
+	_ mngr.Versioned = (*Store)(nil)
+)
+
+// keepFile is the empty file committed in new folders, git doesn't record
+// empty folders.
+const keepFile = ".gitkeep"
+
+// Commit describe the commits created by a Store.
+type Commit struct {
+	Name  string
+	Email string
+	// Message replace the message describing the operation, when not empty.
+	Message string
+}
+
+// DefaultCommit is the author of the changes when none is given.
+var DefaultCommit = Commit{Name: "mngr", Email: "mngr@localhost"}
+
+// Store is a mngr.Store backed by the working tree of a git repository.
+// Files are read from the working tree, every modification is committed.
+type Store struct {
+	mngr.DirStore
+	commit Commit
+	// mu serialize the git commands, they can't share the index.
+	mu *sync.Mutex
+}
+
+// Open return a Store over the git repository located at dir, creating the
+// repository when dir isn't one yet. Changes are committed as DefaultCommit.
+func Open(dir string) (*Store, error) {
+	s := &Store{DirStore: mngr.DirStore(dir), commit: DefaultCommit, mu: &sync.Mutex{}}
+	if _, err := os.Stat(dir + "/.git"); os.IsNotExist(err) {
+		if _, err := s.git("init", "-q"); err != nil {
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+// As return a Store sharing the repository of s whose changes are
+// committed with c.
+func (s *Store) As(c Commit) *Store {
+	if c.Name == "" {
+		c.Name, c.Email = s.commit.Name, s.commit.Email
+	}
+	return &Store{DirStore: s.DirStore, commit: c, mu: s.mu}
+}
+
+// git run a git command in the repository and return its output.
+func (s *Store) git(args ...string) ([]byte, error) {
+	cmd := exec.Command("git", args...)
+	cmd.Dir = string(s.DirStore)
+	cmd.Env = append(os.Environ(),
+		"GIT_AUTHOR_NAME="+s.commit.Name,
+		"GIT_AUTHOR_EMAIL="+s.commit.Email,
+		"GIT_COMMITTER_NAME="+s.commit.Name,
+		"GIT_COMMITTER_EMAIL="+s.commit.Email,
+	)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, &gitError{cmd: args[0], err: err, stderr: strings.TrimSpace(stderr.String())}
+	}
+	return out, nil
+}
+
+// gitError is returned when a git command fails.
+type gitError struct {
+	cmd    string
+	err    error
+	stderr string
+}
+
+func (e *gitError) Error() string {
+	return fmt.Sprintf("git %s: %v: %s", e.cmd, e.err, e.stderr)
+}
+
+// missingObject contains the messages of git commands failing because a
+// revision or a file doesn't exist.
+var missingObject = []string{
+	"does not exist",
+	"does not have any commits",
+	"exists on disk, but not in",
+	"invalid object name",
+	"unknown revision",
+	"bad revision",
+}
+
+// isMissing report whether err is a git error caused by a missing object.
+func isMissing(err error) bool {
+	e, ok := err.(*gitError)
+	if !ok {
+		return false
+	}
+	for _, msg := range missingObject {
+		if strings.Contains(e.stderr, msg) {
+			return true
+		}
+	}
+	return false
+}
+
+// rel return the path of name relative to the repository.
+func rel(name string) string {
+	return strings.TrimPrefix(path.Clean("/"+name), "/")
+}
+
+// hidden report whether a folder or the file of p starts with a dot, like
+// the trash and the snapshots of the wiki. The keepFile of a folder isn't
+// hidden.
+func hidden(p string) bool {
+	names := strings.Split(p, "/")
+	if names[len(names)-1] == keepFile {
+		names = names[:len(names)-1]
+	}
+	for _, n := range names {
+		if strings.HasPrefix(n, ".") {
+			return true
+		}
+	}
+	return false
+}
+
+// commitPaths stage paths and commit them, with message unless s has its
+// own. Nothing is committed when the paths didn't change. The hidden paths
+// and those ignored by the repository are left out of its history.
+func (s *Store) commitPaths(message string, paths ...string) error {
+	for _, p := range paths {
+		if hidden(p) {
+			continue
+		}
+		args := []string{"add", "-A", "--", p}
+		if _, err := s.DirStore.Stat(p); os.IsNotExist(err) {
+			args = []string{"rm", "-r", "-q", "--cached", "--ignore-unmatch", "--", p}
+		} else if _, err := s.git("check-ignore", "-q", "--", p); err == nil {
+			// check-ignore exits with 0 when p is ignored.
+			continue
+		}
+		if _, err := s.git(args...); err != nil {
+			return err
+		}
+	}
+	// diff exits with 1 when there are staged changes.
+	if _, err := s.git("diff", "--cached", "--quiet"); err == nil {
+		return nil
+	}
+	if s.commit.Message != "" {
+		message = s.commit.Message
+	}
+	_, err := s.git("commit", "-q", "-m", message)
+	return err
+}
+
+// Write implements mngr.Store, committing the new content of name.
+func (s *Store) Write(name string, body []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.DirStore.Write(name, body); err != nil {
+		return err
+	}
+	return s.commitPaths("Update "+rel(name), rel(name))
+}
+
+// Create implements mngr.Creator, the file is committed when closed.
+func (s *Store) Create(name string, excl bool) (io.WriteCloser, error) {
+	f, err := s.DirStore.Create(name, excl)
+	if err != nil {
+		return nil, err
+	}
+	return &createdFile{WriteCloser: f, store: s, name: name}, nil
+}
+
+// createdFile is a file written by Create.
+type createdFile struct {
+	io.WriteCloser
+	store *Store
+	name  string
+}
+
+// Close implements io.Closer, committing the file.
+func (f *createdFile) Close() error {
+	if err := f.WriteCloser.Close(); err != nil {
+		return err
+	}
+	f.store.mu.Lock()
+	defer f.store.mu.Unlock()
+	return f.store.commitPaths("Update "+rel(f.name), rel(f.name))
+}
+
+// Abort remove the file without committing it.
+func (f *createdFile) Abort() {
+	f.WriteCloser.Close()
+	f.store.DirStore.Remove(f.name)
+}
+
+// Mkdir implements mngr.Store, committing an empty keep file in the folder.
+func (s *Store) Mkdir(name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.DirStore.Mkdir(name); err != nil {
+		return err
+	}
+	keep := rel(name) + "/" + keepFile
+	if err := s.DirStore.Write(keep, nil); err != nil {
+		return err
+	}
+	return s.commitPaths("Create "+rel(name), keep)
+}
+
+// Remove implements mngr.Store, committing the removal of name.
+func (s *Store) Remove(name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, err := s.DirStore.Stat(name); err != nil {
+		return err
+	}
+	if err := s.DirStore.Remove(name); err != nil {
+		return err
+	}
+	return s.commitPaths("Remove "+rel(name), rel(name))
+}
+
+// Rename implements mngr.Renamer, committing the move as a single change.
+func (s *Store) Rename(from, to string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.DirStore.Rename(from, to); err != nil {
+		return err
+	}
+	return s.commitPaths("Move "+rel(from)+" to "+rel(to), rel(from), rel(to))
+}
+
+// revisionID match the abbreviated or full commit hashes.
+var revisionID = regexp.MustCompile(`^[0-9a-f]{4,40}$`)
+
+// logFormat separate the fields of the commits listed by git log.
+const logFormat = "--format=%H%x1f%an%x1f%aI%x1f%s"
+
+// History implements mngr.Versioned. The renames of a file are followed.
+func (s *Store) History(name string) ([]mngr.Revision, error) {
+	args := []string{"log", logFormat}
+	if fi, err := s.DirStore.Stat(name); err == nil && !fi.IsDir() {
+		args = append(args, "--follow")
+	}
+	args = append(args, "--", rel(name))
+	out, err := s.git(args...)
+	if err != nil {
+		// A repository without commit has no history.
+		if isMissing(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var revisions []mngr.Revision
+	for _, line := range strings.Split(strings.TrimSpace(string(out)), "\n") {
+		fields := strings.Split(line, "\x1f")
+		if len(fields) != 4 {
+			continue
+		}
+		date, err := time.Parse(time.RFC3339, fields[2])
+		if err != nil {
+			return nil, err
+		}
+		revisions = append(revisions, mngr.Revision{ID: fields[0], Author: fields[1], Date: date, Message: fields[3]})
+	}
+	return revisions, nil
+}
+
+// ReadRevision implements mngr.Versioned.
+func (s *Store) ReadRevision(name, id string) ([]byte, error) {
+	if !revisionID.MatchString(id) {
+		return nil, &os.PathError{Op: "read", Path: name + "@" + id, Err: os.ErrNotExist}
+	}
+	body, err := s.git("show", id+":"+rel(name))
+	if isMissing(err) {
+		return nil, &os.PathError{Op: "read", Path: name + "@" + id, Err: os.ErrNotExist}
+	}
+	return body, err
+}
+
+// MakeCommitMiddleware create a middleware making the handlers commit the
+// changes of a request to s as the author returned by author, which may be
+// nil to keep the author of s. A non empty 'message' value of the request
+// is used as commit message. The middleware replace the Store added by
+// mngr.MakeStoreMiddleware.
+func MakeCommitMiddleware(s *Store, author func(*http.Request) (name, email string)) mngr.Middleware {
+	return func(h mngr.Handler) mngr.Handler {
+		return mngr.HandlerFunc(func(w http.ResponseWriter, r *http.Request) (int, error) {
+			var c Commit
+			// Reading the form of multipart requests would consume them.
+			if !strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/") {
+				c.Message = r.FormValue("message")
+			}
+			if author != nil {
+				c.Name, c.Email = author(r)
+			}
+			return mngr.MakeStoreMiddleware(s.As(c))(h).ServeHTTP(w, r)
+		})
+	}
+}
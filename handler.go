@@ -1,31 +1,129 @@
 package mngr
 
 import (
+	"compress/gzip"
+	"context"
+	"errors"
 	"fmt"
+	"html/template"
 	"io"
-	"io/ioutil"
+	"io/fs"
+	"log/slog"
+	"net"
 	"net/http"
 	"os"
-	"regexp"
+	"path"
+	"strings"
 	"time"
 )
 
+// LogOption configure the middleware returned by MakeLogMiddleware.
+type LogOption func(*logConfig)
+
+type logConfig struct {
+	out io.Writer
+	// slog receives the records of the requests instead of out, when set.
+	slog *slog.Logger
+	// format is the Apache log format of the lines, when set.
+	format []logDirective
+	// proxies is set when the clients are resolved through the trusted
+	// proxies.
+	proxies bool
+	trusted []*net.IPNet
+}
+
+// LogTrustedProxies make the log middleware log the address of the client
+// instead of r.RemoteAddr, read from X-Forwarded-For or X-Real-IP when the
+// request comes from one of the trusted proxies or from a unix socket.
+func LogTrustedProxies(trusted []*net.IPNet) LogOption {
+	return func(c *logConfig) {
+		c.proxies, c.trusted = true, trusted
+	}
+}
+
+// LogSlog make the log middleware emit a structured record per request
+// with l, holding the time, remote address, method, path, status, latency,
+// size, error and request ID. Failed requests are logged at the error level.
+func LogSlog(l *slog.Logger) LogOption {
+	return func(c *logConfig) {
+		c.slog = l
+	}
+}
+
+// LogJSON make the log middleware write the records of LogSlog as JSON
+// lines.
+func LogJSON() LogOption {
+	return func(c *logConfig) {
+		c.slog = slog.New(slog.NewJSONHandler(c.out, nil))
+	}
+}
+
+// logRequest write the access log entry of e.
+func (c *logConfig) logRequest(e *logEntry, err error) {
+	r, t, code, elapsed := e.r, e.start, e.code, e.elapsed
+	id := RequestIDFromCtx(r.Context())
+	if c.format != nil {
+		c.writeFormat(e)
+		return
+	}
+	if c.slog == nil {
+		fmt.Fprintln(c.out, e.remote, id, fmt.Sprintf("%0.3fs", elapsed.Seconds()), code, r.Method, r.URL.Path, err)
+		return
+	}
+	level := slog.LevelInfo
+	if code >= http.StatusInternalServerError {
+		level = slog.LevelError
+	}
+	if !c.slog.Enabled(r.Context(), level) {
+		return
+	}
+	// The record is dated with the start of the request.
+	rec := slog.NewRecord(t, level, "request", 0)
+	rec.AddAttrs(
+		slog.String("remote", e.remote),
+		slog.String("method", r.Method),
+		slog.String("path", r.URL.Path),
+		slog.Int("status", code),
+		slog.Duration("latency", elapsed),
+		slog.Int64("size", e.size),
+		slog.String("request_id", id),
+	)
+	if err != nil {
+		rec.AddAttrs(slog.String("error", err.Error()))
+	}
+	c.slog.Handler().Handle(r.Context(), rec)
+}
+
 // MakeLogMiddleware create a logging middleware who wan be plugged into the
-// default Go http.Server. The middleware traces every request and handle
-// the response if mngr.Handler return 0 and an error.
-func MakeLogMiddleware(out io.Writer) func(h Handler) http.HandlerFunc {
+// default Go http.Server. The middleware traces every request with its ID,
+// see RequestIDFromCtx, and handle the response if mngr.Handler return 0 and
+// an error, answered with the code of DefaultErrorStatus, like 404 for
+// ErrNotFound, or 500. Requests are logged to out as a line of space
+// separated fields, unless opts select another format, see LogFormat and
+// LogJSON.
+func MakeLogMiddleware(out io.Writer, opts ...LogOption) func(h Handler) http.HandlerFunc {
+	c := &logConfig{out: out}
+	for _, opt := range opts {
+		opt(c)
+	}
 	return func(h Handler) http.HandlerFunc {
-		return func(w http.ResponseWriter, r *http.Request) {
+		return func(rw http.ResponseWriter, r *http.Request) {
 			t := time.Now()
+			w := &sizeWriter{ResponseWriter: rw}
+			r = withRequestID(w, r)
 			code, err := h.ServeHTTP(w, r)
 			if code == 0 && err != nil {
-				code = http.StatusInternalServerError
-				w.Header().Set("Content-Type", "text/plain")
-				w.WriteHeader(code)
-				fmt.Fprintln(w, err)
+				code = DefaultErrorStatus(err)
+				if code == 0 {
+					code = http.StatusInternalServerError
+				}
+				writeError(w, r, code, err)
+			}
+			remote := r.RemoteAddr
+			if c.proxies {
+				remote = clientIP(r, c.trusted)
 			}
-			elapsed := fmt.Sprintf("%0.3fs", time.Since(t).Seconds())
-			fmt.Fprintln(out, r.RemoteAddr, elapsed, code, r.Method, r.URL.Path, err)
+			c.logRequest(&logEntry{r: r, remote: remote, header: w.Header(), start: t, elapsed: time.Since(t), code: code, size: w.size}, err)
 		}
 	}
 }
@@ -50,27 +148,96 @@ func filterFiles(fInfos []os.FileInfo) (files, folders []string) {
 	return
 }
 
+// FileEntry is a file displayed by the list handler.
+type FileEntry struct {
+	Name  string
+	Title string
+}
+
+// ListOption configure the handler returned by MakeListHandler.
+type ListOption func(*listConfig)
+
+type listConfig struct {
+	titles bool
+	// compressAbove is the entry count above which listings are gzipped,
+	// compression is disabled when negative.
+	compressAbove int
+}
+
+// ListWithTitles make the list handler read every text file to derive its
+// title from the front matter or the first heading. Without it, files are
+// titled after their name.
+func ListWithTitles() ListOption {
+	return func(c *listConfig) {
+		c.titles = true
+	}
+}
+
+// ListCompressAbove make the list handler gzip its response when the folder
+// contains more than n entries and the client accepts it. Responses which
+// already have a Content-Encoding are left untouched.
+func ListCompressAbove(n int) ListOption {
+	return func(c *listConfig) {
+		c.compressAbove = n
+	}
+}
+
+// fileEntries build the FileEntry of the listed files.
+func fileEntries(s Store, dir string, files []string, titles bool) []FileEntry {
+	entries := make([]FileEntry, 0, len(files))
+	for _, name := range files {
+		e := FileEntry{Name: name, Title: name}
+		if titles {
+			body, err := s.Read(dir + name)
+			if err == nil && isText(body) {
+				if title := PageTitle(body); title != "" {
+					e.Title = title
+				}
+			}
+		}
+		entries = append(entries, e)
+	}
+	return entries
+}
+
 // MakeListHandler return an handler wich list folder's content.
-// The handler will list all the file present in dataPath.
-func MakeListHandler(dataPath string) HandlerFunc {
+// The handler will list all the file present in s.
+func MakeListHandler(s Store, opts ...ListOption) HandlerFunc {
+	c := listConfig{compressAbove: -1}
+	for _, opt := range opts {
+		opt(&c)
+	}
 	return func(w http.ResponseWriter, r *http.Request) (int, error) {
 		valid, _ := ValidURLFromCtx(r.Context())
-		fInfos, err := ioutil.ReadDir(dataPath + "/" + valid.Dir)
+		fInfos, err := s.List(valid.Dir)
 		if err != nil {
 			return 0, err
 		}
 		files, folders := filterFiles(fInfos)
 		v := &struct {
 			TemplateInfo
-			Files   []string
+			Files   []FileEntry
 			Folders []string
 		}{
-			TemplateInfo: NewTemplateFromValidURL(valid),
-			Files:        files,
+			TemplateInfo: newTemplateInfo(r, valid),
+			Files:        fileEntries(s, valid.Dir, files, c.titles),
 			Folders:      folders,
 		}
 
 		t, _ := TemplateFromCtx(r.Context())
+		if c.compressAbove >= 0 && len(files)+len(folders) > c.compressAbove &&
+			acceptsEncoding(r, "gzip") && w.Header().Get("Content-Encoding") == "" {
+			w.Header().Set("Content-Type", "text/html; charset=utf-8")
+			w.Header().Set("Content-Encoding", "gzip")
+			w.Header().Add("Vary", "Accept-Encoding")
+			w.Header().Del("Content-Length")
+			gz := gzip.NewWriter(w)
+			err = t.ExecuteTemplate(gz, "list.html", v)
+			if cerr := gz.Close(); err == nil {
+				err = cerr
+			}
+			return 200, err
+		}
 		err = t.ExecuteTemplate(w, "list.html", v)
 		return 200, err
 	}
@@ -78,14 +245,107 @@ func MakeListHandler(dataPath string) HandlerFunc {
 
 // ViewHandler is an handler use to display the content of a file.
 func ViewHandler(w http.ResponseWriter, r *http.Request) (int, error) {
+	return viewPage(w, r, viewConfig{})
+}
+
+// ViewOption configure the handler returned by MakeViewHandler.
+type ViewOption func(*viewConfig)
+
+type viewConfig struct {
+	renderTimeout time.Duration
+	anchors       bool
+	slug          SlugOptions
+	sanitize      bool
+}
+
+// ViewRenderTimeout make the view handler fail when rendering a page takes
+// more than d.
+func ViewRenderTimeout(d time.Duration) ViewOption {
+	return func(c *viewConfig) {
+		c.renderTimeout = d
+	}
+}
+
+// ViewHeadingAnchors make the view handler give every heading an id
+// derived from its text with opts, so sections can be linked to.
+func ViewHeadingAnchors(opts SlugOptions) ViewOption {
+	return func(c *viewConfig) {
+		c.anchors = true
+		c.slug = opts
+	}
+}
+
+// ViewSanitized make the view handler drop raw HTML and unsafe links from
+// rendered pages. Only Markdown files are rendered, other text files are
+// displayed as is.
+func ViewSanitized() ViewOption {
+	return func(c *viewConfig) {
+		c.sanitize = true
+	}
+}
+
+// MakeViewHandler return a ViewHandler configured by opts.
+func MakeViewHandler(opts ...ViewOption) HandlerFunc {
+	var c viewConfig
+	for _, opt := range opts {
+		opt(&c)
+	}
+	return func(w http.ResponseWriter, r *http.Request) (int, error) {
+		return viewPage(w, r, c)
+	}
+}
+
+func viewPage(w http.ResponseWriter, r *http.Request, c viewConfig) (int, error) {
 	valid, _ := ValidURLFromCtx(r.Context())
-	p, err := LoadPage(valid)
+	s, _ := StoreFromCtx(r.Context())
+	if rev := r.URL.Query().Get("rev"); rev != "" {
+		if _, ok := s.(Versioned); !ok {
+			return writeNotVersioned(w)
+		}
+		p, err := LoadRevision(s, valid, rev)
+		if err != nil {
+			return 0, err
+		}
+		return renderPage(w, r, c, p, time.Time{})
+	}
+	p, err := LoadPage(s, valid)
 	if err != nil {
 		path := PagePathFromValidURL(valid)
-		http.Redirect(w, r, "/edit/"+path, http.StatusFound)
+		redirect(w, r, "/edit/"+path, http.StatusFound)
 		return http.StatusFound, nil
 	}
+	var modtime time.Time
+	if fi, err := s.Stat(p.Path); err == nil {
+		modtime = fi.ModTime()
+	}
+	return renderPage(w, r, c, p, modtime)
+}
+
+// renderPage render p, last modified at modtime, with view.html. Clients
+// which already have this rendering are answered with 304.
+func renderPage(w http.ResponseWriter, r *http.Request, c viewConfig, p *Page, modtime time.Time) (int, error) {
+	var err error
+	p.fromRequest(r)
 	t, _ := TemplateFromCtx(r.Context())
+	if checkNotModified(w, r, viewETag(p, t), modtime) {
+		return http.StatusNotModified, nil
+	}
+	if p.Binary {
+		err = t.ExecuteTemplate(w, "view.html", p)
+		return 200, err
+	}
+	if c.sanitize && !markdownExts[strings.ToLower(path.Ext(p.Filename))] {
+		p.Content = renderPlain(p.Body)
+		err = t.ExecuteTemplate(w, "view.html", p)
+		return 200, err
+	}
+	p.Content, err = renderMarkdown(r.Context(), p.Body, c.renderTimeout, c.sanitize)
+	if err != nil {
+		return 0, fmt.Errorf("rendering %s: %v", p.Path, err)
+	}
+	if c.anchors {
+		p.Content = template.HTML(addHeadingAnchors(string(p.Content), c.slug))
+	}
 	err = t.ExecuteTemplate(w, "view.html", p)
 	return 200, err
 }
@@ -93,43 +353,252 @@ func ViewHandler(w http.ResponseWriter, r *http.Request) (int, error) {
 // EditHandler is an handler use to edit the content of a file.
 func EditHandler(w http.ResponseWriter, r *http.Request) (int, error) {
 	valid, _ := ValidURLFromCtx(r.Context())
-	p, err := LoadPage(valid)
+	s, _ := StoreFromCtx(r.Context())
+	p, err := LoadPage(s, valid)
 	if err != nil {
-		p = NewPage(valid, nil)
+		p = NewPage(s, valid, nil)
 	}
+	p.fromRequest(r)
 	t, _ := TemplateFromCtx(r.Context())
 	err = t.ExecuteTemplate(w, "edit.html", p)
 	return 200, err
 }
 
+// HashHeader is the header of the responses to the saves holding the hash
+// of the content saved, to send back as the 'hash' form value of the next
+// save of the page.
+const HashHeader = "X-Page-Hash"
+
 // SaveHandler is an handler use to save the content of a page in a file.
+// When the 'hash' form value is sent, like the edit page does, the page
+// must not have changed since it was loaded: stale saves are answered with
+// 409 and conflict.html, which displays the changes and a form to merge
+// them. The PUT requests and the autosaves get the 409 without the page.
 func SaveHandler(w http.ResponseWriter, r *http.Request) (int, error) {
 	valid, _ := ValidURLFromCtx(r.Context())
+	s, _ := StoreFromCtx(r.Context())
 	body := r.FormValue("body")
-	p := NewPage(valid, []byte(body))
-	err := p.save()
+	p := NewPage(s, valid, []byte(body))
+	code, err := checkConflict(w, r, p, func() ([]byte, error) {
+		return s.Read(p.Path)
+	})
+	if code != 0 || err != nil {
+		return code, err
+	}
+	err = p.save()
 	if err != nil {
 		return 0, err
 	}
-	http.Redirect(w, r, "/view/"+p.Path, http.StatusFound)
+	return savedResponse(w, r, p)
+}
+
+// checkConflict compare the 'hash' form value of the save of p with the
+// hash of the current content of the page, returned by current, or an
+// empty hash when the page doesn't exist. On mismatch, it answer the
+// request and return its status, it return 0 when the save can go on.
+func checkConflict(w http.ResponseWriter, r *http.Request, p *Page, current func() ([]byte, error)) (int, error) {
+	sent, ok := r.Form["hash"]
+	if !ok {
+		return 0, nil
+	}
+	body, err := current()
+	hash := ""
+	switch {
+	case err == nil:
+		hash = bodyHash(body)
+	case !errors.Is(err, fs.ErrNotExist):
+		return 0, err
+	}
+	if sent[0] == hash {
+		return 0, nil
+	}
+	w.Header().Set(HashHeader, hash)
+	if r.Method == http.MethodPut || r.FormValue("autosave") != "" {
+		return 0, fmt.Errorf("%w: %s changed since it was loaded", ErrConflict, p.Path)
+	}
+
+	p.fromRequest(r)
+	p.Hash = hash
+	conflict := &struct {
+		*Page
+		Message string
+		Lines   []DiffLine
+	}{
+		Page:    p,
+		Message: r.FormValue("message"),
+	}
+	p.Binary = !isText(body) || !isText(p.Body)
+	if !p.Binary {
+		conflict.Lines = diffLines(splitLines(body), splitLines(p.Body))
+	}
+	t, _ := TemplateFromCtx(r.Context())
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(http.StatusConflict)
+	err = t.ExecuteTemplate(w, "conflict.html", conflict)
+	return http.StatusConflict, err
+}
+
+// savedResponse answer the request which saved p: PUT requests with 204,
+// the forms with a redirect to the page.
+func savedResponse(w http.ResponseWriter, r *http.Request, p *Page) (int, error) {
+	w.Header().Set(HashHeader, bodyHash(p.Body))
+	if r.Method == http.MethodPut {
+		w.WriteHeader(http.StatusNoContent)
+		return http.StatusNoContent, nil
+	}
+	redirect(w, r, "/view/"+p.Path, http.StatusFound)
 	return http.StatusFound, nil
 }
 
-// FolderHandler is a HandlerFunc use to create new folder.
+// MakeSaveHandler return a SaveHandler coalescing autosaves with d.
+// Requests with a non empty 'autosave' form value are handed to d and answered
+// with 204, others are written immediately, replacing any pending autosave.
+// The hash of a pending autosave is the one the next save must send.
+// A nil d return SaveHandler.
+func MakeSaveHandler(d *SaveDebouncer) HandlerFunc {
+	if d == nil {
+		return SaveHandler
+	}
+	return func(w http.ResponseWriter, r *http.Request) (int, error) {
+		valid, _ := ValidURLFromCtx(r.Context())
+		s, _ := StoreFromCtx(r.Context())
+		body := r.FormValue("body")
+		p := NewPage(s, valid, []byte(body))
+		code, err := checkConflict(w, r, p, func() ([]byte, error) {
+			if body, ok := d.pendingBody(p.Path); ok {
+				return body, nil
+			}
+			return s.Read(p.Path)
+		})
+		if code != 0 || err != nil {
+			return code, err
+		}
+		if r.FormValue("autosave") != "" {
+			// The page is written after the request ended, with a Store
+			// unbound from its context.
+			unbound, _ := r.Context().Value(storeKey).(Store)
+			p.store = StoreWithContext(context.Background(), unbound)
+			d.Save(p)
+			w.Header().Set(HashHeader, bodyHash(p.Body))
+			w.WriteHeader(http.StatusNoContent)
+			return http.StatusNoContent, nil
+		}
+		err = d.SaveNow(p)
+		if err != nil {
+			return 0, err
+		}
+		return savedResponse(w, r, p)
+	}
+}
+
+// FolderHandler is a HandlerFunc use to create new folder, with POST
+// requests.
 func FolderHandler(w http.ResponseWriter, r *http.Request) (int, error) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		w.Header().Set("Content-Type", "text/plain")
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		w.Write([]byte("method not allowed"))
+		return http.StatusMethodNotAllowed, nil
+	}
 	valid, _ := ValidURLFromCtx(r.Context())
-	err := NewFolder(valid)
+	s, _ := StoreFromCtx(r.Context())
+	err := NewFolder(s, valid)
 	if err != nil {
 		return 0, err
 	}
-	http.Redirect(w, r, "/list/"+valid.Dir, http.StatusFound)
+	redirect(w, r, "/list/"+valid.Dir, http.StatusFound)
 	return http.StatusFound, nil
 }
 
+// DeleteHandler is an handler use to delete a file or a folder.
+// GET requests display a confirmation page, POST requests delete and
+// redirect to the folder, DELETE requests delete and are answered with 204.
+func DeleteHandler(w http.ResponseWriter, r *http.Request) (int, error) {
+	valid, _ := ValidURLFromCtx(r.Context())
+	s, _ := StoreFromCtx(r.Context())
+	fi, err := s.Stat(PagePathFromValidURL(valid))
+	if err != nil {
+		return 0, err
+	}
+	if r.Method != http.MethodPost && r.Method != http.MethodDelete {
+		info := newTemplateInfo(r, valid)
+		info.IsDir = fi.IsDir()
+		t, _ := TemplateFromCtx(r.Context())
+		err = t.ExecuteTemplate(w, "delete.html", info)
+		return 200, err
+	}
+	err = DeletePath(s, valid)
+	if err != nil {
+		return 0, err
+	}
+	if r.Method == http.MethodDelete {
+		w.WriteHeader(http.StatusNoContent)
+		return http.StatusNoContent, nil
+	}
+	redirect(w, r, "/list/"+valid.Dir, http.StatusFound)
+	return http.StatusFound, nil
+}
+
+// MoveHandler is an handler use to rename or move a file or a folder.
+// GET requests display a form, POST requests move the file to the 'to'
+// path, validated with the same rules as MakeNewHandler, and redirect to it.
+func MoveHandler(w http.ResponseWriter, r *http.Request) (int, error) {
+	return targetForm(w, r, MovePath)
+}
+
+// CopyHandler is an handler use to duplicate a file or a folder.
+// It works like MoveHandler but leaves the original in place.
+func CopyHandler(w http.ResponseWriter, r *http.Request) (int, error) {
+	return targetForm(w, r, CopyPath)
+}
+
+// targetForm display the target.html form asking for a 'to' path and,
+// on POST, apply do to the requested file and the validated path.
+func targetForm(w http.ResponseWriter, r *http.Request, do func(Store, ValidURL, string) error) (int, error) {
+	valid, _ := ValidURLFromCtx(r.Context())
+	from := strings.TrimPrefix(PagePathFromValidURL(valid), "/")
+	s, _ := StoreFromCtx(r.Context())
+	fi, err := s.Stat(from)
+	if err != nil {
+		return 0, err
+	}
+	to := strings.Trim(r.FormValue("to"), "/")
+	isValid := true
+	if r.Method == http.MethodPost {
+		_, name := path.Split(to)
+		isValid = validPagePath.MatchString(to) && validName.MatchString(name)
+		if isValid {
+			err = do(s, valid, to)
+			if err != nil {
+				return 0, err
+			}
+			url := "/view/" + to
+			if fi.IsDir() {
+				url = "/list/" + to + "/"
+			}
+			redirect(w, r, url, http.StatusFound)
+			return http.StatusFound, nil
+		}
+	}
+
+	p := &struct {
+		TemplateInfo
+		Path    string
+		IsValid bool
+	}{
+		TemplateInfo: newTemplateInfo(r, valid),
+		Path:         from,
+		IsValid:      isValid,
+	}
+	p.IsDir = fi.IsDir()
+	t, _ := TemplateFromCtx(r.Context())
+	err = t.ExecuteTemplate(w, "target.html", p)
+	return 200, err
+}
+
 // MakeNewHandler return an HandlerFunc which deals with file and folder creation.
 func MakeNewHandler() HandlerFunc {
-	// Safeguard, may be usefull later.
-	validName := regexp.MustCompile("^[a-zA-Z0-9]+[a-zA-Z0-9.]*$")
 	return func(w http.ResponseWriter, r *http.Request) (int, error) {
 		valid, _ := ValidURLFromCtx(r.Context())
 		if valid.Value != "file" && valid.Value != "folder" {
@@ -139,19 +608,21 @@ func MakeNewHandler() HandlerFunc {
 			return http.StatusBadRequest, nil
 		}
 
-		name := r.URL.Query().Get("name")
-		path := r.URL.Query().Get("path")
+		name := r.FormValue("name")
+		path := r.FormValue("path")
 		isValid := true
 		if name != "" {
 			isValid = validName.MatchString(name)
 			if isValid {
 				path = path + name
-				url := "/edit/" + path
+				// The folder is created by a POST to FolderHandler, 307 keeps
+				// the method and the form.
+				url, code := "/edit/"+path, http.StatusSeeOther
 				if valid.Value == "folder" {
-					url = "/folder/" + path
+					url, code = "/folder/"+path, http.StatusTemporaryRedirect
 				}
-				http.Redirect(w, r, url, http.StatusFound)
-				return http.StatusFound, nil
+				redirect(w, r, url, code)
+				return code, nil
 			}
 		}
 
@@ -160,7 +631,7 @@ func MakeNewHandler() HandlerFunc {
 			Path    string
 			IsValid bool
 		}{
-			TemplateInfo: NewTemplateFromValidURL(valid),
+			TemplateInfo: newTemplateInfo(r, valid),
 			Path:         path,
 			IsValid:      isValid,
 		}
@@ -0,0 +1,28 @@
+package mngr
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+)
+
+// hashFile return the hex encoded SHA-256 of the content of the file name,
+// streamed when s is an Opener.
+func hashFile(s Store, name string) (string, error) {
+	f, err := openFile(s, name)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// bodyHash return the hex encoded SHA-256 of body.
+func bodyHash(body []byte) string {
+	sum := sha256.Sum256(body)
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,112 @@
+package mngr
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// HealthCheck verify a dependency of the wiki, for MakeReadyHandler.
+type HealthCheck struct {
+	Name  string
+	Check func(ctx context.Context) error
+}
+
+// DirCheck verify that the folder located at path can be read and written,
+// by creating then removing a hidden file.
+func DirCheck(path string) HealthCheck {
+	return HealthCheck{Name: "dir " + path, Check: func(ctx context.Context) error {
+		if _, err := os.ReadDir(path); err != nil {
+			return err
+		}
+		f, err := os.CreateTemp(path, ".healthz-")
+		if err != nil {
+			return err
+		}
+		name := f.Name()
+		err = f.Close()
+		if rerr := os.Remove(name); err == nil {
+			err = rerr
+		}
+		return err
+	}}
+}
+
+// StoreCheck verify that s can be reached, by looking up a missing file.
+func StoreCheck(s Store) HealthCheck {
+	return HealthCheck{Name: "store", Check: func(ctx context.Context) error {
+		_, err := s.Stat(".healthz")
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return nil
+	}}
+}
+
+// SearchCheck verify that idx was built.
+func SearchCheck(idx *SearchIndex) HealthCheck {
+	return HealthCheck{Name: "search", Check: func(ctx context.Context) error {
+		if !idx.Ready() {
+			return errors.New("index not built yet")
+		}
+		return nil
+	}}
+}
+
+// MakeHealthHandler return an handler answering 200 as long as the server
+// runs, for liveness probes.
+func MakeHealthHandler() HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) (int, error) {
+		w.Header().Set("Content-Type", "text/plain")
+		w.Header().Set("Cache-Control", "no-store")
+		w.Write([]byte("ok\n"))
+		return http.StatusOK, nil
+	}
+}
+
+// MakeReadyHandler return an handler running checks in parallel, for
+// readiness probes. It answers 200 when they all pass within timeout, 503
+// otherwise, with the result of every check.
+func MakeReadyHandler(timeout time.Duration, checks ...HealthCheck) HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) (int, error) {
+		ctx, cancel := context.WithTimeout(r.Context(), timeout)
+		defer cancel()
+		results := make([]chan error, len(checks))
+		for i, c := range checks {
+			results[i] = make(chan error, 1)
+			go func(c HealthCheck, res chan<- error) {
+				res <- c.Check(ctx)
+			}(c, results[i])
+		}
+		code := http.StatusOK
+		var b strings.Builder
+		for i, c := range checks {
+			var err error
+			select {
+			case err = <-results[i]:
+			case <-ctx.Done():
+				// Keep the results of the checks done in time.
+				select {
+				case err = <-results[i]:
+				default:
+					err = ctx.Err()
+				}
+			}
+			status := "ok"
+			if err != nil {
+				code = http.StatusServiceUnavailable
+				status = err.Error()
+			}
+			fmt.Fprintf(&b, "%s: %s\n", c.Name, status)
+		}
+		w.Header().Set("Content-Type", "text/plain")
+		w.Header().Set("Cache-Control", "no-store")
+		w.WriteHeader(code)
+		w.Write([]byte(b.String()))
+		return code, nil
+	}
+}
@@ -0,0 +1,124 @@
+package mngr
+
+import (
+	"errors"
+	"net/http"
+	"time"
+)
+
+var errNotVersioned = errors.New("the store doesn't keep the history of pages")
+
+// Revision is a recorded version of a file.
+type Revision struct {
+	ID      string
+	Author  string
+	Date    time.Time
+	Message string
+}
+
+// Versioned is implemented by stores keeping the history of their files.
+type Versioned interface {
+	// History return the revisions of the file name, most recent first.
+	History(name string) ([]Revision, error)
+	// ReadRevision return the content of the file name at revision id.
+	ReadRevision(name, id string) ([]byte, error)
+}
+
+// writeNotVersioned answer a request needing the history of a store which
+// doesn't keep one.
+func writeNotVersioned(w http.ResponseWriter) (int, error) {
+	w.Header().Set("Content-Type", "text/plain")
+	w.WriteHeader(http.StatusNotImplemented)
+	w.Write([]byte(errNotVersioned.Error()))
+	return http.StatusNotImplemented, nil
+}
+
+// LoadRevision load the page located at v as it was at revision id.
+func LoadRevision(s Store, v ValidURL, id string) (*Page, error) {
+	versioned, ok := s.(Versioned)
+	if !ok {
+		return nil, errNotVersioned
+	}
+	path := PagePathFromValidURL(v)
+	body, err := versioned.ReadRevision(path, id)
+	if err != nil {
+		return nil, err
+	}
+	p := NewPage(s, v, body)
+	p.Binary = !isText(body)
+	if !p.Binary {
+		p.Title = PageTitle(body)
+	}
+	p.Revision = id
+	return p, nil
+}
+
+// historyEntry is a revision listed by HistoryHandler.
+type historyEntry struct {
+	Revision
+	// Previous is the ID of the revision preceding this one, if any.
+	Previous string
+}
+
+// HistoryHandler is an handler listing the revisions of a page, using
+// history.html. Each revision links to a read-only view of the page and to
+// the changes it made.
+func HistoryHandler(w http.ResponseWriter, r *http.Request) (int, error) {
+	valid, _ := ValidURLFromCtx(r.Context())
+	s, _ := StoreFromCtx(r.Context())
+	versioned, ok := s.(Versioned)
+	if !ok {
+		return writeNotVersioned(w)
+	}
+	revisions, err := versioned.History(PagePathFromValidURL(valid))
+	if err != nil {
+		return 0, err
+	}
+	entries := make([]historyEntry, len(revisions))
+	for i, rev := range revisions {
+		entries[i].Revision = rev
+		if i+1 < len(revisions) {
+			entries[i].Previous = revisions[i+1].ID
+		}
+	}
+	p := &struct {
+		TemplateInfo
+		Path      string
+		Revisions []historyEntry
+	}{
+		TemplateInfo: newTemplateInfo(r, valid),
+		Path:         PagePathFromValidURL(valid),
+		Revisions:    entries,
+	}
+	t, _ := TemplateFromCtx(r.Context())
+	err = t.ExecuteTemplate(w, "history.html", p)
+	return 200, err
+}
+
+// RevertHandler is an handler restoring the revision, given by the 'rev'
+// value, of a page. GET requests display a confirmation page, POST requests
+// save the content of the revision as the current one, the history is
+// never rewritten.
+func RevertHandler(w http.ResponseWriter, r *http.Request) (int, error) {
+	valid, _ := ValidURLFromCtx(r.Context())
+	s, _ := StoreFromCtx(r.Context())
+	if _, ok := s.(Versioned); !ok {
+		return writeNotVersioned(w)
+	}
+	p, err := LoadRevision(s, valid, r.FormValue("rev"))
+	if err != nil {
+		return 0, err
+	}
+	if r.Method != http.MethodPost {
+		p.fromRequest(r)
+		t, _ := TemplateFromCtx(r.Context())
+		err = t.ExecuteTemplate(w, "revert.html", p)
+		return 200, err
+	}
+	err = p.save()
+	if err != nil {
+		return 0, err
+	}
+	redirect(w, r, "/view/"+p.Path, http.StatusFound)
+	return http.StatusFound, nil
+}
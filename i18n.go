@@ -0,0 +1,157 @@
+package mngr
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"io/fs"
+	"net/http"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// sourceLang is the language of the messages of the templates.
+const sourceLang = "en"
+
+// Catalog holds the translations of the messages of the templates, by
+// language. The messages are keyed by their English text, which is
+// displayed when a translation is missing.
+type Catalog struct {
+	langs map[string]map[string]string
+}
+
+// LoadCatalog load the message catalogs of fsys, like DefaultLocales. Each
+// 'lang.json' file, like 'fr.json' or 'pt-br.json', holds an object mapping
+// the English messages to their translation in the language named after
+// the file.
+func LoadCatalog(fsys fs.FS) (*Catalog, error) {
+	files, err := fs.Glob(fsys, "*.json")
+	if err != nil {
+		return nil, err
+	}
+	c := &Catalog{langs: make(map[string]map[string]string, len(files))}
+	for _, name := range files {
+		b, err := fs.ReadFile(fsys, name)
+		if err != nil {
+			return nil, err
+		}
+		var messages map[string]string
+		if err := json.Unmarshal(b, &messages); err != nil {
+			return nil, fmt.Errorf("mngr: catalog %s: %v", name, err)
+		}
+		c.langs[strings.ToLower(strings.TrimSuffix(name, path.Ext(name)))] = messages
+	}
+	return c, nil
+}
+
+// Languages return the languages of the catalog, English included.
+func (c *Catalog) Languages() []string {
+	langs := []string{sourceLang}
+	for lang := range c.langs {
+		if lang != sourceLang {
+			langs = append(langs, lang)
+		}
+	}
+	sort.Strings(langs)
+	return langs
+}
+
+// Translate return the translation of msg in lang, or in the base language
+// of lang, like 'pt' for 'pt-br', falling back to msg. When args are given,
+// the translation is a format of fmt.Sprintf. The messages are trusted,
+// like the templates, but the args are escaped.
+func (c *Catalog) Translate(lang, msg string, args ...interface{}) template.HTML {
+	lang = strings.ToLower(lang)
+	translated, ok := c.langs[lang][msg]
+	if !ok {
+		if i := strings.IndexByte(lang, '-'); i > 0 {
+			translated, ok = c.langs[lang[:i]][msg]
+		}
+	}
+	if !ok || translated == "" {
+		translated = msg
+	}
+	if len(args) == 0 {
+		return template.HTML(translated)
+	}
+	escaped := make([]interface{}, len(args))
+	for i, arg := range args {
+		escaped[i] = template.HTMLEscapeString(fmt.Sprint(arg))
+	}
+	return template.HTML(fmt.Sprintf(translated, escaped...))
+}
+
+// has report whether the catalog holds the language lang.
+func (c *Catalog) has(lang string) bool {
+	_, ok := c.langs[lang]
+	return ok || lang == sourceLang
+}
+
+// Negotiate return the language of the catalog best matching the
+// Accept-Language header accept, by order of quality, or English when none
+// match. A language matches its own tag, then its base language.
+func (c *Catalog) Negotiate(accept string) string {
+	type choice struct {
+		lang string
+		q    float64
+	}
+	var choices []choice
+	for _, part := range strings.Split(accept, ",") {
+		fields := strings.Split(part, ";")
+		lang := strings.ToLower(strings.TrimSpace(fields[0]))
+		if lang == "" {
+			continue
+		}
+		q := 1.0
+		for _, param := range fields[1:] {
+			param = strings.TrimSpace(param)
+			if strings.HasPrefix(param, "q=") {
+				q, _ = strconv.ParseFloat(param[2:], 64)
+			}
+		}
+		if q > 0 {
+			choices = append(choices, choice{lang, q})
+		}
+	}
+	sort.SliceStable(choices, func(i, j int) bool { return choices[i].q > choices[j].q })
+	for _, ch := range choices {
+		if ch.lang == "*" {
+			break
+		}
+		if c.has(ch.lang) {
+			return ch.lang
+		}
+		if i := strings.IndexByte(ch.lang, '-'); i > 0 && c.has(ch.lang[:i]) {
+			return ch.lang[:i]
+		}
+	}
+	return sourceLang
+}
+
+type langCtxKey int
+
+var langKey = langCtxKey(0)
+
+// LangFromCtx return the language of the request negotiated by
+// MakeLanguageMiddleware, or an empty string.
+func LangFromCtx(c context.Context) string {
+	lang, _ := c.Value(langKey).(string)
+	return lang
+}
+
+// MakeLanguageMiddleware create a middleware choosing the language of the
+// pages among those of c, from the request's Accept-Language header. The
+// language reaches the templates as TemplateInfo.Lang.
+func MakeLanguageMiddleware(c *Catalog) Middleware {
+	return func(h Handler) Handler {
+		return HandlerFunc(func(w http.ResponseWriter, r *http.Request) (int, error) {
+			w.Header().Add("Vary", "Accept-Language")
+			lang := c.Negotiate(r.Header.Get("Accept-Language"))
+			ctx := context.WithValue(r.Context(), langKey, lang)
+			return h.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
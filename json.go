@@ -0,0 +1,15 @@
+package mngr
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// writeJSON encode v as the JSON response body, with the given status code.
+// It return the status code and the encoding error, ready to be returned by
+// an Handler.
+func writeJSON(w http.ResponseWriter, code int, v interface{}) (int, error) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(code)
+	return code, json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,104 @@
+package mngr
+
+import (
+	"context"
+	"net/http"
+	"path"
+	"time"
+)
+
+// LinkRef is a relative link from a page to another file of the wiki.
+type LinkRef struct {
+	// Source is the path of the page containing the link.
+	Source string
+	// Target is the path the link resolve to.
+	Target string
+	Link   Link
+}
+
+// LinkIndex keep the relative links between the files of the wiki.
+// The index is built by walking every text page and is kept for a limited
+// duration, or until Invalidate is called.
+type LinkIndex struct {
+	store   Store
+	workers int
+	cache   *ttlCache
+}
+
+// NewLinkIndex create a LinkIndex over s, rebuilt at most every ttl.
+// Up to workers files are read in parallel when building the index.
+func NewLinkIndex(s Store, ttl time.Duration, workers int) *LinkIndex {
+	return &LinkIndex{
+		store:   s,
+		workers: workers,
+		cache:   newTTLCache(ttl),
+	}
+}
+
+// Invalidate drop the index, it will be rebuilt on the next use.
+func (idx *LinkIndex) Invalidate() {
+	idx.cache.drop("")
+}
+
+// Refs return every relative link of the wiki, ordered by source page.
+func (idx *LinkIndex) Refs(ctx context.Context) ([]LinkRef, error) {
+	refs, err := idx.cache.get("", func() (interface{}, error) {
+		return idx.build(ctx)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return refs.([]LinkRef), nil
+}
+
+// To return the links targeting the file located at p.
+func (idx *LinkIndex) To(ctx context.Context, p string) ([]LinkRef, error) {
+	refs, err := idx.Refs(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var to []LinkRef
+	for _, ref := range refs {
+		if ref.Target == p {
+			to = append(to, ref)
+		}
+	}
+	return to, nil
+}
+
+func (idx *LinkIndex) build(ctx context.Context) ([]LinkRef, error) {
+	refs := []LinkRef{}
+	err := walkPages(ctx, idx.store, "", nil, idx.workers, func(source string, body []byte) error {
+		if !isText(body) {
+			return nil
+		}
+		for _, l := range ExtractLinks(body) {
+			p, ok := relativeTarget(l.Target)
+			if !ok {
+				continue
+			}
+			target, ok := resolveLink(path.Dir(source), p)
+			if !ok {
+				continue
+			}
+			refs = append(refs, LinkRef{Source: source, Target: target, Link: l})
+		}
+		return nil
+	})
+	return refs, err
+}
+
+// MakeLinkIndexMiddleware create a middleware invalidating idx every time
+// the next Handler succeed with an unsafe method. It is meant to wrap the
+// handlers modifying pages.
+func MakeLinkIndexMiddleware(idx *LinkIndex) Middleware {
+	return func(h Handler) Handler {
+		return HandlerFunc(func(w http.ResponseWriter, r *http.Request) (int, error) {
+			code, err := h.ServeHTTP(w, r)
+			if err == nil && code < http.StatusBadRequest && !safeMethods[r.Method] {
+				idx.Invalidate()
+			}
+			return code, err
+		})
+	}
+}
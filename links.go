@@ -0,0 +1,88 @@
+package mngr
+
+import (
+	"net/url"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// Link is a link found in the content of a page.
+type Link struct {
+	Text   string `json:"text"`
+	Target string `json:"target"`
+	Image  bool   `json:"image,omitempty"`
+	Wiki   bool   `json:"wiki,omitempty"`
+}
+
+var (
+	codeBlock  = regexp.MustCompile("(?ms)^\\s*(```|~~~).*?^\\s*(```|~~~)\\s*$|`[^`\n]*`")
+	inlineLink = regexp.MustCompile(`(!?)\[([^\]]*)\]\(\s*<?([^)\s>]+)>?(?:\s+["'(][^)]*)?\)`)
+	refLinkDef = regexp.MustCompile(`(?m)^ {0,3}\[([^\]]+)\]:\s*<?([^\s>]+)>?`)
+	wikiLink   = regexp.MustCompile(`\[\[([^\]|]+)(?:\|([^\]]*))?\]\]`)
+)
+
+// ExtractLinks return the inline links, images, reference definitions and
+// '[[target|text]]' wiki links found in a Markdown body, grouped by kind in
+// order of appearance. Code spans and fenced code blocks are ignored.
+func ExtractLinks(body []byte) []Link {
+	text := codeBlock.ReplaceAllString(string(body), "")
+	var links []Link
+	for _, m := range inlineLink.FindAllStringSubmatch(text, -1) {
+		links = append(links, Link{Text: m[2], Target: m[3], Image: m[1] == "!"})
+	}
+	for _, m := range refLinkDef.FindAllStringSubmatch(text, -1) {
+		links = append(links, Link{Text: m[1], Target: m[2]})
+	}
+	for _, m := range wikiLink.FindAllStringSubmatch(text, -1) {
+		target := strings.TrimSpace(m[1])
+		linkText := strings.TrimSpace(m[2])
+		if linkText == "" {
+			linkText = target
+		}
+		links = append(links, Link{Text: linkText, Target: target, Wiki: true})
+	}
+	return links
+}
+
+// pageExts contains the extensions of files considered as pages rather
+// than assets.
+var pageExts = map[string]bool{
+	"":          true,
+	".md":       true,
+	".markdown": true,
+	".txt":      true,
+	".html":     true,
+	".htm":      true,
+}
+
+// relativeTarget return the path of a link relative to the page
+// containing it, without query or fragment. ok is false for absolute URLs,
+// site absolute paths and anchors.
+func relativeTarget(target string) (p string, ok bool) {
+	if strings.HasPrefix(target, "/") || strings.HasPrefix(target, "#") {
+		return "", false
+	}
+	u, err := url.Parse(target)
+	if err != nil || u.Scheme != "" || u.Host != "" || u.Path == "" {
+		return "", false
+	}
+	return u.Path, true
+}
+
+// isAsset report whether a relative link targets an asset, an image or a
+// file which is not a page.
+func isAsset(l Link, p string) bool {
+	return l.Image || !pageExts[strings.ToLower(path.Ext(p))]
+}
+
+// resolveLink return the path, relative to the data folder, of a relative
+// link found in a page of dir. ok is false when the link leaves the data
+// folder.
+func resolveLink(dir, p string) (resolved string, ok bool) {
+	resolved = path.Join(dir, p)
+	if resolved == ".." || strings.HasPrefix(resolved, "../") {
+		return "", false
+	}
+	return resolved, true
+}
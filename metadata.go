@@ -0,0 +1,60 @@
+package mngr
+
+import (
+	"context"
+	"net/http"
+	"time"
+)
+
+// MetadataIssue is a page missing some required front matter keys.
+type MetadataIssue struct {
+	Path    string   `json:"path"`
+	Missing []string `json:"missing"`
+}
+
+// auditMetadata return the readable text pages located under dir in s
+// missing one or more of the required front matter keys, see walkFiles.
+func auditMetadata(ctx context.Context, s Store, dir string, required []string, readable func(p string) bool, workers int) ([]MetadataIssue, error) {
+	issues := []MetadataIssue{}
+	err := walkPages(ctx, s, dir, readable, workers, func(path string, body []byte) error {
+		if !isText(body) {
+			return nil
+		}
+		fm := ParseFrontMatter(body)
+		var missing []string
+		for _, key := range required {
+			if fm[key] == "" {
+				missing = append(missing, key)
+			}
+		}
+		if len(missing) > 0 {
+			issues = append(issues, MetadataIssue{Path: path, Missing: missing})
+		}
+		return nil
+	})
+	return issues, err
+}
+
+// MakeMetadataAuditHandler return an handler listing, as JSON, the pages
+// located under the requested folder which lack one of the required front
+// matter keys. Results are cached per folder, and user, for ttl and up to
+// workers files are read in parallel.
+func MakeMetadataAuditHandler(s Store, required []string, ttl time.Duration, workers int) ContextHandlerFunc {
+	cache := newTTLCache(ttl)
+	return func(ctx context.Context, w http.ResponseWriter, r *http.Request) (int, error) {
+		valid, _ := ValidURLFromCtx(ctx)
+		issues, err := cache.get(userCacheKey(ctx, valid.Dir), func() (interface{}, error) {
+			return auditMetadata(ctx, s, valid.Dir, required, readFilter(ctx), workers)
+		})
+		if err != nil {
+			return 0, err
+		}
+		return writeJSON(w, http.StatusOK, struct {
+			Required []string        `json:"required"`
+			Pages    []MetadataIssue `json:"pages"`
+		}{
+			Required: required,
+			Pages:    issues.([]MetadataIssue),
+		})
+	}
+}
@@ -0,0 +1,161 @@
+package mngr
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultLatencyBuckets are the upper bounds, in seconds, of the request
+// latency histogram buckets.
+var DefaultLatencyBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// routeMetrics holds the metrics of a route.
+type routeMetrics struct {
+	inFlight int64
+	// statuses counts the requests by status class, like "2xx".
+	statuses map[string]uint64
+	// buckets counts the requests by latency bucket, the last one is +Inf.
+	buckets []uint64
+	sum     float64
+	count   uint64
+}
+
+// Metrics collects the metrics of the requests served through
+// MakeMetricsMiddleware, exposed by MakeMetricsHandler in the Prometheus
+// text format.
+type Metrics struct {
+	bounds []float64
+
+	mu     sync.Mutex
+	routes map[string]*routeMetrics
+}
+
+// NewMetrics create Metrics with the latency histogram buckets bounds, in
+// seconds. DefaultLatencyBuckets are used when bounds is empty.
+func NewMetrics(bounds ...float64) *Metrics {
+	if len(bounds) == 0 {
+		bounds = DefaultLatencyBuckets
+	}
+	bounds = append([]float64(nil), bounds...)
+	sort.Float64s(bounds)
+	return &Metrics{bounds: bounds, routes: make(map[string]*routeMetrics)}
+}
+
+// route return the metrics of route, m.mu must be held.
+func (m *Metrics) route(route string) *routeMetrics {
+	rm, ok := m.routes[route]
+	if !ok {
+		rm = &routeMetrics{statuses: make(map[string]uint64), buckets: make([]uint64, len(m.bounds)+1)}
+		m.routes[route] = rm
+	}
+	return rm
+}
+
+// begin count a request in flight on route.
+func (m *Metrics) begin(route string) {
+	m.mu.Lock()
+	m.route(route).inFlight++
+	m.mu.Unlock()
+}
+
+// end record a request on route, answered with code after elapsed.
+func (m *Metrics) end(route string, code int, elapsed time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	rm := m.route(route)
+	rm.inFlight--
+	rm.statuses[strconv.Itoa(code/100)+"xx"]++
+	seconds := elapsed.Seconds()
+	i := sort.SearchFloat64s(m.bounds, seconds)
+	rm.buckets[i]++
+	rm.sum += seconds
+	rm.count++
+}
+
+// MakeMetricsMiddleware create a middleware recording in m the requests
+// count by status class, the latency and the requests in flight of every
+// route. Routes are named after the pattern of the http.ServeMux serving
+// them. Requests returning 0 and an error count as 500.
+func MakeMetricsMiddleware(m *Metrics) Middleware {
+	return func(h Handler) Handler {
+		return HandlerFunc(func(w http.ResponseWriter, r *http.Request) (int, error) {
+			route := r.Pattern
+			if route == "" {
+				route = "other"
+			}
+			t := time.Now()
+			m.begin(route)
+			code, err := h.ServeHTTP(w, r)
+			status := code
+			if status == 0 {
+				status = http.StatusOK
+				if err != nil {
+					status = http.StatusInternalServerError
+				}
+			}
+			m.end(route, status, time.Since(t))
+			return code, err
+		})
+	}
+}
+
+// formatFloat format f like the Prometheus text format.
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
+
+// MakeMetricsHandler return an handler exposing m in the Prometheus text
+// format, to be served at /metrics.
+func MakeMetricsHandler(m *Metrics) HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) (int, error) {
+		m.mu.Lock()
+		routes := make([]string, 0, len(m.routes))
+		for route := range m.routes {
+			routes = append(routes, route)
+		}
+		sort.Strings(routes)
+		var b strings.Builder
+		b.WriteString("# HELP mngr_requests_total Requests served, by route and status class.\n")
+		b.WriteString("# TYPE mngr_requests_total counter\n")
+		for _, route := range routes {
+			rm := m.routes[route]
+			classes := make([]string, 0, len(rm.statuses))
+			for class := range rm.statuses {
+				classes = append(classes, class)
+			}
+			sort.Strings(classes)
+			for _, class := range classes {
+				fmt.Fprintf(&b, "mngr_requests_total{route=%q,status=%q} %d\n", route, class, rm.statuses[class])
+			}
+		}
+		b.WriteString("# HELP mngr_request_duration_seconds Latency of the requests, by route.\n")
+		b.WriteString("# TYPE mngr_request_duration_seconds histogram\n")
+		for _, route := range routes {
+			rm := m.routes[route]
+			var cumulative uint64
+			for i, bound := range m.bounds {
+				cumulative += rm.buckets[i]
+				fmt.Fprintf(&b, "mngr_request_duration_seconds_bucket{route=%q,le=%q} %d\n", route, formatFloat(bound), cumulative)
+			}
+			fmt.Fprintf(&b, "mngr_request_duration_seconds_bucket{route=%q,le=\"+Inf\"} %d\n", route, rm.count)
+			fmt.Fprintf(&b, "mngr_request_duration_seconds_sum{route=%q} %s\n", route, formatFloat(rm.sum))
+			fmt.Fprintf(&b, "mngr_request_duration_seconds_count{route=%q} %d\n", route, rm.count)
+		}
+		b.WriteString("# HELP mngr_requests_in_flight Requests being served, by route.\n")
+		b.WriteString("# TYPE mngr_requests_in_flight gauge\n")
+		for _, route := range routes {
+			fmt.Fprintf(&b, "mngr_requests_in_flight{route=%q} %d\n", route, m.routes[route].inFlight)
+		}
+		m.mu.Unlock()
+
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		_, err := io.WriteString(w, b.String())
+		return http.StatusOK, err
+	}
+}
@@ -1,6 +1,9 @@
 package mngr
 
-import "net/http"
+import (
+	"context"
+	"net/http"
+)
 
 // The code in this file is extracted from
 // github.com/mholt/caddy/caddyhttp/httpserver/middleware.go
@@ -37,9 +40,80 @@ type (
 	// ServeHTTP returns a status code and an error. See Handler
 	// documentation for more information.
 	HandlerFunc func(http.ResponseWriter, *http.Request) (int, error)
+
+	// ContextHandler is like Handler except ServeContext is given the
+	// context of the request explicitly, following the same contract.
+	//
+	// Long running operations, like walking the Store or searching, should
+	// be given ctx so they stop when the client goes away or the deadline
+	// of the request passes, see MakeTimeoutMiddleware.
+	ContextHandler interface {
+		ServeContext(ctx context.Context, w http.ResponseWriter, r *http.Request) (int, error)
+	}
+
+	// ContextHandlerFunc is a convenience type like HandlerFunc for
+	// ContextHandler. It is an Handler too, serving the requests with their
+	// context.
+	ContextHandlerFunc func(context.Context, http.ResponseWriter, *http.Request) (int, error)
 )
 
 // ServeHTTP implements the Handler interface.
 func (f HandlerFunc) ServeHTTP(w http.ResponseWriter, r *http.Request) (int, error) {
 	return f(w, r)
 }
+
+// Chain return a middleware plugging the middlewares mws in their
+// declared order: the first one is the outermost, receiving the requests
+// first, and the last one calls the Handler given to the chain. So
+// Chain(a, b, c)(h) is a(b(c(h))). An empty chain returns the Handler
+// unchanged. MakeLogMiddleware, returning an http.Handler, wraps a chain:
+// log(Chain(recover, auth)(h)).
+func Chain(mws ...Middleware) Middleware {
+	return func(h Handler) Handler {
+		for i := len(mws) - 1; i >= 0; i-- {
+			h = mws[i](h)
+		}
+		return h
+	}
+}
+
+// ServeContext implements the ContextHandler interface.
+func (f ContextHandlerFunc) ServeContext(ctx context.Context, w http.ResponseWriter, r *http.Request) (int, error) {
+	return f(ctx, w, r)
+}
+
+// ServeHTTP implements the Handler interface, with the context of r.
+func (f ContextHandlerFunc) ServeHTTP(w http.ResponseWriter, r *http.Request) (int, error) {
+	return f(r.Context(), w, r)
+}
+
+// FromContextHandler return an Handler serving the requests with h, given
+// the context of the request.
+func FromContextHandler(h ContextHandler) Handler {
+	return HandlerFunc(func(w http.ResponseWriter, r *http.Request) (int, error) {
+		return h.ServeContext(r.Context(), w, r)
+	})
+}
+
+// Wrap return an Handler serving the requests with the http.Handler h, like
+// the pprof handlers or an http.FileServer, so it can be plugged after the
+// mngr middlewares. It returns the status written by h.
+func Wrap(h http.Handler) HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) (int, error) {
+		sw := &statusWriter{ResponseWriter: w}
+		h.ServeHTTP(sw, r)
+		return sw.status, nil
+	}
+}
+
+// ToHTTP return an http.Handler serving the requests with h, so it can be
+// given to an http.ServeMux or to the middlewares of other packages. When h
+// return 0 and an error, it is answered with the code of
+// DefaultErrorStatus, like MakeLogMiddleware does, without logging it.
+func ToHTTP(h Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if code, err := h.ServeHTTP(w, r); code == 0 && err != nil {
+			writeError(w, r, DefaultErrorStatus(err), err)
+		}
+	})
+}
@@ -0,0 +1,43 @@
+package mngr
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"net/http"
+	"strings"
+)
+
+type nonceCtxKey int
+
+var nonceKey = nonceCtxKey(0)
+
+// DefaultNoncePolicy is a Content-Security-Policy only allowing same origin
+// resources, plus inline scripts and styles carrying the request's nonce.
+const DefaultNoncePolicy = "default-src 'self'; script-src 'self' 'nonce-{nonce}'; style-src 'self' 'nonce-{nonce}'"
+
+// NonceFromCtx extract the nonce added by MakeNonceMiddleware from a
+// context. It return an empty string when there is none.
+func NonceFromCtx(ctx context.Context) string {
+	nonce, _ := ctx.Value(nonceKey).(string)
+	return nonce
+}
+
+// MakeNonceMiddleware create a middleware generating a random nonce for
+// every request. The nonce is added to the request's context, from where it
+// reaches TemplateInfo.Nonce, and replaces '{nonce}' in policy to build the
+// Content-Security-Policy header of the response.
+func MakeNonceMiddleware(policy string) Middleware {
+	return func(h Handler) Handler {
+		return HandlerFunc(func(w http.ResponseWriter, r *http.Request) (int, error) {
+			b := make([]byte, 16)
+			if _, err := rand.Read(b); err != nil {
+				return 0, err
+			}
+			nonce := base64.StdEncoding.EncodeToString(b)
+			w.Header().Set("Content-Security-Policy", strings.Replace(policy, "{nonce}", nonce, -1))
+			ctx := context.WithValue(r.Context(), nonceKey, nonce)
+			return h.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
@@ -0,0 +1,122 @@
+// Package oidcauth implements a mngr.AuthProvider delegating the login of
+// the users to an OpenID Connect provider, like Google or Keycloak, with
+// the authorization code flow.
+package oidcauth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/aitva/mngr"
+	"github.com/coreos/go-oidc/v3/oidc"
+	"golang.org/x/oauth2"
+)
+
+var _ mngr.AuthProvider = (*Provider)(nil)
+
+// Config describe the client registered with an OpenID Connect provider.
+type Config struct {
+	// Issuer is the URL of the provider, its configuration is discovered
+	// from Issuer + "/.well-known/openid-configuration".
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	// RedirectURL is the URL of the mngr.MakeProviderCallbackHandler.
+	RedirectURL string
+	// Scopes are requested in addition to "openid", "email" and "profile"
+	// when empty.
+	Scopes []string
+	// Claim is the claim of the ID token naming the users, "email" when
+	// empty. Unverified emails are rejected.
+	Claim string
+	// Users, when not empty, are the only users allowed to log in.
+	Users []string
+}
+
+// Provider is a mngr.AuthProvider logging in the users of an OpenID
+// Connect provider.
+type Provider struct {
+	oauth    *oauth2.Config
+	verifier *oidc.IDTokenVerifier
+	claim    string
+	users    map[string]bool
+}
+
+// New create a Provider from c, fetching the configuration of the issuer.
+func New(ctx context.Context, c Config) (*Provider, error) {
+	provider, err := oidc.NewProvider(ctx, c.Issuer)
+	if err != nil {
+		return nil, err
+	}
+	scopes := c.Scopes
+	if len(scopes) == 0 {
+		scopes = []string{"email", "profile"}
+	}
+	p := &Provider{
+		oauth: &oauth2.Config{
+			ClientID:     c.ClientID,
+			ClientSecret: c.ClientSecret,
+			Endpoint:     provider.Endpoint(),
+			RedirectURL:  c.RedirectURL,
+			Scopes:       append([]string{oidc.ScopeOpenID}, scopes...),
+		},
+		verifier: provider.Verifier(&oidc.Config{ClientID: c.ClientID}),
+		claim:    c.Claim,
+	}
+	if p.claim == "" {
+		p.claim = "email"
+	}
+	if len(c.Users) > 0 {
+		p.users = make(map[string]bool, len(c.Users))
+		for _, u := range c.Users {
+			p.users[u] = true
+		}
+	}
+	return p, nil
+}
+
+// LoginURL implements mngr.AuthProvider. The state is also used as nonce
+// of the ID token.
+func (p *Provider) LoginURL(state string) string {
+	return p.oauth.AuthCodeURL(state, oidc.Nonce(state))
+}
+
+// Callback implements mngr.AuthProvider, exchanging the authorization code
+// for an ID token and verifying it.
+func (p *Provider) Callback(r *http.Request) (string, error) {
+	if e := r.FormValue("error"); e != "" {
+		return "", fmt.Errorf("oidc: %s: %s", e, r.FormValue("error_description"))
+	}
+	token, err := p.oauth.Exchange(r.Context(), r.FormValue("code"))
+	if err != nil {
+		return "", err
+	}
+	raw, ok := token.Extra("id_token").(string)
+	if !ok {
+		return "", errors.New("oidc: no id_token in the token response")
+	}
+	idToken, err := p.verifier.Verify(r.Context(), raw)
+	if err != nil {
+		return "", err
+	}
+	if idToken.Nonce != r.FormValue("state") {
+		return "", errors.New("oidc: invalid nonce")
+	}
+	var claims map[string]interface{}
+	if err := idToken.Claims(&claims); err != nil {
+		return "", err
+	}
+	user, _ := claims[p.claim].(string)
+	if user == "" {
+		return "", fmt.Errorf("oidc: no %s claim in the id token", p.claim)
+	}
+	if verified, ok := claims["email_verified"].(bool); p.claim == "email" && ok && !verified {
+		return "", errors.New("oidc: email not verified")
+	}
+	if p.users != nil && !p.users[user] {
+		return "", fmt.Errorf("oidc: user %s not allowed", user)
+	}
+	return user, nil
+}
@@ -0,0 +1,71 @@
+package mngr
+
+import (
+	"net/http"
+	"strings"
+)
+
+// MakeOptionsMiddleware create a middleware answering OPTIONS requests for
+// a route. The response lists the route's methods in the Allow header and,
+// when description is not empty, describes the endpoint as JSON. Other
+// requests are passed to the next Handler.
+func MakeOptionsMiddleware(description string, methods ...string) Middleware {
+	allowed := append([]string{}, methods...)
+	hasOptions := false
+	for _, m := range allowed {
+		hasOptions = hasOptions || m == http.MethodOptions
+	}
+	if !hasOptions {
+		allowed = append(allowed, http.MethodOptions)
+	}
+	allow := strings.Join(allowed, ", ")
+	return func(h Handler) Handler {
+		return HandlerFunc(func(w http.ResponseWriter, r *http.Request) (int, error) {
+			if r.Method != http.MethodOptions {
+				return h.ServeHTTP(w, r)
+			}
+			w.Header().Set("Allow", allow)
+			if description == "" {
+				w.WriteHeader(http.StatusNoContent)
+				return http.StatusNoContent, nil
+			}
+			return writeJSON(w, http.StatusOK, struct {
+				Methods     []string `json:"methods"`
+				Description string   `json:"description"`
+			}{
+				Methods:     allowed,
+				Description: description,
+			})
+		})
+	}
+}
+
+// MakeMethodMiddleware create a middleware restricting a route to methods,
+// HEAD being allowed with GET. OPTIONS requests are answered with the
+// allowed methods, like MakeOptionsMiddleware does, and the other requests
+// with 405, so a route mutating the wiki can't be reached with GET.
+func MakeMethodMiddleware(methods ...string) Middleware {
+	allowed := make(map[string]bool, len(methods)+1)
+	list := append([]string{}, methods...)
+	for _, m := range methods {
+		allowed[m] = true
+	}
+	if allowed[http.MethodGet] && !allowed[http.MethodHead] {
+		allowed[http.MethodHead] = true
+		list = append(list, http.MethodHead)
+	}
+	options := MakeOptionsMiddleware("", list...)
+	allow := strings.Join(append(list, http.MethodOptions), ", ")
+	return func(h Handler) Handler {
+		return options(HandlerFunc(func(w http.ResponseWriter, r *http.Request) (int, error) {
+			if allowed[r.Method] {
+				return h.ServeHTTP(w, r)
+			}
+			w.Header().Set("Allow", allow)
+			w.Header().Set("Content-Type", "text/plain")
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			w.Write([]byte("method not allowed"))
+			return http.StatusMethodNotAllowed, nil
+		}))
+	}
+}
@@ -0,0 +1,121 @@
+// Package oteltrace traces the requests served by mngr with OpenTelemetry.
+//
+// MakeMiddleware starts a server span per request, continuing the trace
+// propagated by the client if any, and adds a mngr.Tracer to the context so
+// the operations of the Store and the rendering of the templates are traced
+// in child spans.
+package oteltrace
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/aitva/mngr"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var _ mngr.Tracer = (*Tracer)(nil)
+
+// instrumentation is the name of the tracer, following the OpenTelemetry
+// conventions.
+const instrumentation = "github.com/aitva/mngr/oteltrace"
+
+// Option configure a Tracer.
+type Option func(*config)
+
+type config struct {
+	provider   trace.TracerProvider
+	propagator propagation.TextMapPropagator
+}
+
+// WithTracerProvider make the Tracer create its spans with p instead of the
+// global provider.
+func WithTracerProvider(p trace.TracerProvider) Option {
+	return func(c *config) {
+		c.provider = p
+	}
+}
+
+// WithPropagator make the Tracer read the trace of the clients with p
+// instead of the global propagator.
+func WithPropagator(p propagation.TextMapPropagator) Option {
+	return func(c *config) {
+		c.propagator = p
+	}
+}
+
+// Tracer is a mngr.Tracer creating OpenTelemetry spans.
+type Tracer struct {
+	tracer     trace.Tracer
+	propagator propagation.TextMapPropagator
+}
+
+// New create a Tracer configured by opts.
+func New(opts ...Option) *Tracer {
+	c := config{
+		provider:   otel.GetTracerProvider(),
+		propagator: otel.GetTextMapPropagator(),
+	}
+	for _, opt := range opts {
+		opt(&c)
+	}
+	return &Tracer{tracer: c.provider.Tracer(instrumentation), propagator: c.propagator}
+}
+
+// Start implements mngr.Tracer.
+func (t *Tracer) Start(ctx context.Context, name string) (context.Context, func(err error)) {
+	ctx, span := t.tracer.Start(ctx, name)
+	return ctx, func(err error) {
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+		}
+		span.End()
+	}
+}
+
+// MakeMiddleware create a middleware tracing every request in a server span
+// of t, named after the method and the route of the http.ServeMux, and
+// adding t to the request's context with mngr.MakeTracerMiddleware.
+// Responses with a 5xx status, or an error, mark the span as failed.
+func MakeMiddleware(t *Tracer) mngr.Middleware {
+	return func(h mngr.Handler) mngr.Handler {
+		h = mngr.MakeTracerMiddleware(t)(h)
+		return mngr.HandlerFunc(func(w http.ResponseWriter, r *http.Request) (int, error) {
+			ctx := t.propagator.Extract(r.Context(), propagation.HeaderCarrier(r.Header))
+			name := r.Method
+			if r.Pattern != "" {
+				name += " " + r.Pattern
+			}
+			ctx, span := t.tracer.Start(ctx, name,
+				trace.WithSpanKind(trace.SpanKindServer),
+				trace.WithAttributes(
+					attribute.String("http.request.method", r.Method),
+					attribute.String("http.route", r.Pattern),
+					attribute.String("url.path", r.URL.Path),
+					attribute.String("mngr.request_id", mngr.RequestIDFromCtx(r.Context())),
+				),
+			)
+			defer span.End()
+			code, err := h.ServeHTTP(w, r.WithContext(ctx))
+			if code != 0 {
+				span.SetAttributes(attribute.Int("http.response.status_code", code))
+			}
+			if err != nil {
+				span.RecordError(err)
+			}
+			if code >= http.StatusInternalServerError || (code == 0 && err != nil) {
+				msg := http.StatusText(code)
+				if err != nil {
+					msg = err.Error()
+				}
+				span.SetStatus(codes.Error, msg)
+			}
+			return code, err
+		})
+	}
+}
@@ -1,54 +1,119 @@
 package mngr
 
 import (
-	"io/ioutil"
+	"fmt"
+	"html/template"
+	"net/http"
 	"os"
+	"path"
+	"strings"
 )
 
-const pagesPath = "data"
-
 // Page represet a wiki page.
 type Page struct {
 	TemplateInfo
 	Path     string
 	Filename string
 	Body     []byte
+	// Binary is set when Body doesn't contain text.
+	Binary bool
+	// Content is the rendered Body, it is only set by ViewHandler.
+	Content template.HTML
+	// Revision is the revision of the page loaded by LoadRevision, it is
+	// empty for the current content.
+	Revision string
+	// Hash is the hash of the content loaded by LoadPage, the edit page
+	// sends it back so SaveHandler can detect concurrent edits.
+	Hash string
+	// store is where the page is saved.
+	store Store
 }
 
 func (p *Page) save() error {
-	path := pagesPath + "/" + p.Path
-	return ioutil.WriteFile(path, p.Body, 0600)
+	return p.store.Write(p.Path, p.Body)
 }
 
 func PagePathFromValidURL(v ValidURL) string {
 	return v.Dir + "/" + v.Value
 }
 
-func LoadPage(v ValidURL) (*Page, error) {
+func LoadPage(s Store, v ValidURL) (*Page, error) {
 	path := PagePathFromValidURL(v)
-	body, err := ioutil.ReadFile(pagesPath + "/" + path)
+	body, err := s.Read(path)
 	if err != nil {
 		return nil, err
 	}
+	info := NewTemplateFromValidURL(v)
+	binary := !isText(body)
+	if !binary {
+		info.Title = PageTitle(body)
+	}
 	return &Page{
-		TemplateInfo: NewTemplateFromValidURL(v),
+		TemplateInfo: info,
 		Path:         path,
 		Filename:     v.Value,
 		Body:         body,
+		Binary:       binary,
+		Hash:         bodyHash(body),
+		store:        s,
 	}, nil
 }
 
-func NewPage(v ValidURL, body []byte) *Page {
+func NewPage(s Store, v ValidURL, body []byte) *Page {
 	return &Page{
 		TemplateInfo: NewTemplateFromValidURL(v),
 		Path:         PagePathFromValidURL(v),
 		Filename:     v.Value,
 		Body:         body,
+		store:        s,
+	}
+}
+
+func NewFolder(s Store, v ValidURL) error {
+	return s.Mkdir(v.Dir + "/" + v.Value)
+}
+
+// DeletePath move the file or the folder, with its content, located at v to
+// the trash. It stays there until TrashHandler restores or purges it.
+func DeletePath(s Store, v ValidURL) error {
+	return moveToTrash(s, PagePathFromValidURL(v))
+}
+
+// MovePath rename the file or the folder located at v to the path to,
+// relative to the root of s. It fails when to already exists or is inside
+// the folder moved.
+func MovePath(s Store, v ValidURL, to string) error {
+	from := PagePathFromValidURL(v)
+	if err := checkTarget("move", from, to); err != nil {
+		return err
+	}
+	if _, err := s.Stat(to); err == nil {
+		return &os.PathError{Op: "move", Path: to, Err: os.ErrExist}
+	}
+	return rename(s, from, to)
+}
+
+// CopyPath copy the file or the folder, with its content, located at v to
+// the path to, relative to the root of s. It fails when to already exists
+// or is inside the folder copied.
+func CopyPath(s Store, v ValidURL, to string) error {
+	from := PagePathFromValidURL(v)
+	if err := checkTarget("copy", from, to); err != nil {
+		return err
 	}
+	if _, err := s.Stat(to); err == nil {
+		return &os.PathError{Op: "copy", Path: to, Err: os.ErrExist}
+	}
+	return copyTree(s, from, to)
 }
 
-func NewFolder(v ValidURL) error {
-	path := pagesPath + "/" + v.Dir + "/" + v.Value
-	err := os.Mkdir(path, 0700)
-	return err
+// checkTarget return a bad request error when the target to of op is the
+// path from or is below it, a folder can't be moved or copied into itself.
+func checkTarget(op, from, to string) error {
+	from = strings.Trim(path.Clean("/"+from), "/")
+	to = strings.Trim(path.Clean("/"+to), "/")
+	if to == from || from == "" || strings.HasPrefix(to, from+"/") {
+		return NewHTTPError(http.StatusBadRequest, fmt.Errorf("%s /%s: target /%s inside the source", op, from, to))
+	}
+	return nil
 }
@@ -0,0 +1,58 @@
+package mngr
+
+import (
+	"net/http"
+	"sort"
+	"strconv"
+)
+
+// PageRank is the number of distinct pages linking to a page.
+type PageRank struct {
+	Path  string `json:"path"`
+	Count int    `json:"count"`
+}
+
+// MakePopularHandler return an handler listing, as JSON, the existing pages
+// of the wiki by decreasing number of other pages linking to them. The
+// 'limit' query value caps the number of pages returned. Only the pages
+// the ACL of the request, if any, lets the user read are counted.
+func MakePopularHandler(idx *LinkIndex) HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) (int, error) {
+		refs, err := idx.Refs(r.Context())
+		if err != nil {
+			return 0, err
+		}
+		readable := readFilter(r.Context())
+		sources := make(map[string]map[string]bool)
+		for _, ref := range refs {
+			if ref.Source == ref.Target {
+				continue
+			}
+			if readable != nil && (!readable(ref.Source) || !readable(ref.Target)) {
+				continue
+			}
+			if sources[ref.Target] == nil {
+				sources[ref.Target] = make(map[string]bool)
+			}
+			sources[ref.Target][ref.Source] = true
+		}
+		ranks := []PageRank{}
+		for target, from := range sources {
+			if _, err := idx.store.Stat(target); err != nil {
+				continue
+			}
+			ranks = append(ranks, PageRank{Path: target, Count: len(from)})
+		}
+		sort.Slice(ranks, func(i, j int) bool {
+			if ranks[i].Count != ranks[j].Count {
+				return ranks[i].Count > ranks[j].Count
+			}
+			return ranks[i].Path < ranks[j].Path
+		})
+		limit, err := strconv.Atoi(r.URL.Query().Get("limit"))
+		if err == nil && limit >= 0 && limit < len(ranks) {
+			ranks = ranks[:limit]
+		}
+		return writeJSON(w, http.StatusOK, ranks)
+	}
+}
@@ -0,0 +1,91 @@
+package mngr
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"net/http"
+	"strings"
+)
+
+// AuthProvider delegate the login of the users to an external service, like
+// an OpenID Connect provider.
+type AuthProvider interface {
+	// LoginURL return the URL of the service where to send the user to log
+	// in. The service redirects the user back with state.
+	LoginURL(state string) string
+	// Callback complete the login from the request the service redirected
+	// the user with, and return the name of the user.
+	Callback(r *http.Request) (user string, err error)
+}
+
+// loginCookie is the name of the cookie holding the state of a login with
+// an AuthProvider.
+const loginCookie = "mngr_login"
+
+// MakeProviderLoginHandler return an handler sending the users to log in
+// with p. Once logged in they are redirected to the local URL given by the
+// 'next' value, see MakeProviderCallbackHandler.
+func MakeProviderLoginHandler(p AuthProvider) HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) (int, error) {
+		next := r.FormValue("next")
+		if !localURL(next) {
+			next = "/"
+		}
+		b := make([]byte, 16)
+		if _, err := rand.Read(b); err != nil {
+			return 0, err
+		}
+		state := base64.RawURLEncoding.EncodeToString(b)
+		http.SetCookie(w, &http.Cookie{
+			Name:     loginCookie,
+			Value:    state + "." + base64.RawURLEncoding.EncodeToString([]byte(next)),
+			Path:     "/",
+			MaxAge:   600,
+			Secure:   r.TLS != nil,
+			HttpOnly: true,
+			SameSite: http.SameSiteLaxMode,
+		})
+		http.Redirect(w, r, p.LoginURL(state), http.StatusFound)
+		return http.StatusFound, nil
+	}
+}
+
+// MakeProviderCallbackHandler return the handler receiving the users back
+// from p. It checks the 'state' value, starts a session in s for the user
+// returned by p and redirects to the URL requested at login. Failed logins
+// are answered with 401.
+func MakeProviderCallbackHandler(s *Sessions, p AuthProvider) HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) (int, error) {
+		unauthorized := func(msg string) (int, error) {
+			w.Header().Set("Content-Type", "text/plain")
+			w.WriteHeader(http.StatusUnauthorized)
+			w.Write([]byte("unauthorized: " + msg))
+			return http.StatusUnauthorized, nil
+		}
+		c, err := r.Cookie(loginCookie)
+		if err != nil {
+			return unauthorized("no login in progress")
+		}
+		http.SetCookie(w, &http.Cookie{Name: loginCookie, Path: "/", MaxAge: -1})
+		i := strings.IndexByte(c.Value, '.')
+		if i < 0 {
+			return unauthorized("invalid login state")
+		}
+		state := r.FormValue("state")
+		if subtle.ConstantTimeCompare([]byte(state), []byte(c.Value[:i])) != 1 {
+			return unauthorized("invalid login state")
+		}
+		next, err := base64.RawURLEncoding.DecodeString(c.Value[i+1:])
+		if err != nil || !localURL(string(next)) {
+			next = []byte("/")
+		}
+		user, err := p.Callback(r)
+		if err != nil {
+			return unauthorized(err.Error())
+		}
+		s.Login(w, r, user)
+		redirect(w, r, string(next), http.StatusFound)
+		return http.StatusFound, nil
+	}
+}
@@ -0,0 +1,87 @@
+package mngr
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// ParseCIDRs parse a comma separated list of networks, like
+// "10.0.0.0/8,127.0.0.1". Addresses without mask are a network of their own.
+func ParseCIDRs(s string) ([]*net.IPNet, error) {
+	var nets []*net.IPNet
+	for _, field := range strings.Split(s, ",") {
+		field = strings.TrimSpace(field)
+		if field == "" {
+			continue
+		}
+		if !strings.Contains(field, "/") {
+			ip := net.ParseIP(field)
+			if ip == nil {
+				return nil, fmt.Errorf("invalid address %q", field)
+			}
+			bits := 8 * net.IPv6len
+			if ip.To4() != nil {
+				ip, bits = ip.To4(), 8*net.IPv4len
+			}
+			nets = append(nets, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, n, err := net.ParseCIDR(field)
+		if err != nil {
+			return nil, err
+		}
+		nets = append(nets, n)
+	}
+	return nets, nil
+}
+
+// containsIP report whether ip is in one of nets.
+func containsIP(nets []*net.IPNet, ip net.IP) bool {
+	for _, n := range nets {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// overUnixSocket report whether r was received on a unix socket.
+func overUnixSocket(r *http.Request) bool {
+	_, ok := r.Context().Value(http.LocalAddrContextKey).(*net.UnixAddr)
+	return ok
+}
+
+// clientIP return the address of the client making r. When the request
+// comes from a trusted proxy, or a unix socket as its peers are local
+// proxies, the client is the last address of the X-Forwarded-For header
+// which isn't a trusted proxy, or the X-Real-IP header without
+// X-Forwarded-For.
+func clientIP(r *http.Request, trusted []*net.IPNet) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	ip := net.ParseIP(host)
+	if !overUnixSocket(r) && (ip == nil || !containsIP(trusted, ip)) {
+		return host
+	}
+	forwarded := r.Header.Values("X-Forwarded-For")
+	if len(forwarded) == 0 {
+		forwarded = r.Header.Values("X-Real-IP")
+	}
+	forwarded = strings.Split(strings.Join(forwarded, ","), ",")
+	for i := len(forwarded) - 1; i >= 0; i-- {
+		addr := strings.TrimSpace(forwarded[i])
+		fip := net.ParseIP(addr)
+		if fip == nil {
+			break
+		}
+		host = fip.String()
+		if !containsIP(trusted, fip) {
+			break
+		}
+	}
+	return host
+}
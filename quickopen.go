@@ -0,0 +1,109 @@
+package mngr
+
+import (
+	"context"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// FileMatch is a file whose path fuzzy match a query. Positions are the
+// indexes, in runes, of the matched characters of the path.
+type FileMatch struct {
+	Path      string `json:"path"`
+	Score     int    `json:"score"`
+	Positions []int  `json:"positions"`
+}
+
+// fuzzyMatch report whether the runes of query appear, in order, in name,
+// ignoring case. Matches are scored higher when they are consecutive, start
+// a word or are located in the base name. Characters are matched greedily,
+// from the left.
+func fuzzyMatch(name, query string) (int, []int, bool) {
+	runes := []rune(name)
+	base := strings.LastIndex(name, "/")
+	base = len([]rune(name[:base+1]))
+	score := 0
+	positions := []int{}
+	i := 0
+	for _, q := range strings.ToLower(query) {
+		if unicode.IsSpace(q) {
+			continue
+		}
+		for i < len(runes) && unicode.ToLower(runes[i]) != q {
+			i++
+		}
+		if i == len(runes) {
+			return 0, nil, false
+		}
+		score++
+		if n := len(positions); n > 0 && positions[n-1] == i-1 {
+			score += 5
+		}
+		if i == 0 || strings.ContainsRune("/._- ", runes[i-1]) {
+			score += 3
+		}
+		if i >= base {
+			score += 2
+		}
+		positions = append(positions, i)
+		i++
+	}
+	return score, positions, true
+}
+
+// MakeQuickOpenHandler return an handler listing, as JSON, the files of s
+// whose path fuzzy match the 'q' query value, best match first, for an
+// autocomplete box. The list of files is cached for ttl. At most limit
+// matches are returned, the 'limit' query value can lower it. The files the
+// ACL of the request, if any, doesn't let the user read are left out.
+func MakeQuickOpenHandler(s Store, ttl time.Duration, limit int) ContextHandlerFunc {
+	cache := newTTLCache(ttl)
+	return func(ctx context.Context, w http.ResponseWriter, r *http.Request) (int, error) {
+		q := strings.TrimSpace(r.URL.Query().Get("q"))
+		matches := []FileMatch{}
+		if q == "" {
+			return writeJSON(w, http.StatusOK, matches)
+		}
+		v, err := cache.get("", func() (interface{}, error) {
+			var files []string
+			err := walkFiles(ctx, s, "", nil, func(path string) error {
+				files = append(files, path)
+				return nil
+			})
+			return files, err
+		})
+		if err != nil {
+			return 0, err
+		}
+		readable := readFilter(ctx)
+		for _, name := range v.([]string) {
+			if readable != nil && !readable(name) {
+				continue
+			}
+			if score, positions, ok := fuzzyMatch(name, q); ok {
+				matches = append(matches, FileMatch{Path: name, Score: score, Positions: positions})
+			}
+		}
+		sort.Slice(matches, func(i, j int) bool {
+			if matches[i].Score != matches[j].Score {
+				return matches[i].Score > matches[j].Score
+			}
+			if len(matches[i].Path) != len(matches[j].Path) {
+				return len(matches[i].Path) < len(matches[j].Path)
+			}
+			return matches[i].Path < matches[j].Path
+		})
+		n := limit
+		if l, err := strconv.Atoi(r.URL.Query().Get("limit")); err == nil && l > 0 && (n <= 0 || l < n) {
+			n = l
+		}
+		if n > 0 && len(matches) > n {
+			matches = matches[:n]
+		}
+		return writeJSON(w, http.StatusOK, matches)
+	}
+}
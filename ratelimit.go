@@ -0,0 +1,78 @@
+package mngr
+
+import (
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// bucket is the token bucket of a client.
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// rateLimiter holds the token buckets of the clients.
+type rateLimiter struct {
+	rate  float64
+	burst float64
+
+	mu      sync.Mutex
+	buckets map[string]*bucket
+	swept   time.Time
+}
+
+// allow take a token from the bucket of key. When the bucket is empty,
+// it return false and the delay before the next token.
+func (l *rateLimiter) allow(key string, now time.Time) (bool, time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if now.Sub(l.swept) > time.Minute {
+		// Forget the clients whose bucket is full again.
+		for k, b := range l.buckets {
+			if b.tokens+now.Sub(b.last).Seconds()*l.rate >= l.burst {
+				delete(l.buckets, k)
+			}
+		}
+		l.swept = now
+	}
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &bucket{tokens: l.burst, last: now}
+		l.buckets[key] = b
+	}
+	b.tokens = math.Min(l.burst, b.tokens+now.Sub(b.last).Seconds()*l.rate)
+	b.last = now
+	if b.tokens < 1 {
+		return false, time.Duration((1 - b.tokens) / l.rate * float64(time.Second))
+	}
+	b.tokens--
+	return true, 0
+}
+
+// MakeRateLimitMiddleware create a middleware limiting every client to rate
+// requests per second, with bursts of up to burst requests. Clients are
+// identified by their IP, read from X-Forwarded-For or X-Real-IP when the
+// request comes from a trusted proxy. Throttled requests are answered with
+// 429, and logged like the others by MakeLogMiddleware.
+func MakeRateLimitMiddleware(rate float64, burst int, trusted []*net.IPNet) Middleware {
+	l := &rateLimiter{rate: rate, burst: float64(burst), buckets: make(map[string]*bucket)}
+	return func(h Handler) Handler {
+		return HandlerFunc(func(w http.ResponseWriter, r *http.Request) (int, error) {
+			ip := clientIP(r, trusted)
+			ok, wait := l.allow(ip, time.Now())
+			if ok {
+				return h.ServeHTTP(w, r)
+			}
+			retry := int(math.Ceil(wait.Seconds()))
+			w.Header().Set("Retry-After", strconv.Itoa(retry))
+			w.Header().Set("Content-Type", "text/plain")
+			w.WriteHeader(http.StatusTooManyRequests)
+			w.Write([]byte("too many requests"))
+			return http.StatusTooManyRequests, nil
+		})
+	}
+}
@@ -0,0 +1,50 @@
+package mngr
+
+import (
+	"context"
+	"net/http"
+)
+
+type readOnlyCtxKey int
+
+var readOnlyKey = readOnlyCtxKey(0)
+
+// readOnlyFromCtx report whether MakeReadOnlyMiddleware marked a context.
+func readOnlyFromCtx(ctx context.Context) bool {
+	ro, _ := ctx.Value(readOnlyKey).(bool)
+	return ro
+}
+
+// MakeReadOnlyMiddleware create a middleware rejecting the requests
+// modifying the wiki, the actions requiring RoleEditor or more in
+// MakeACLMiddleware. They are answered with 403, rendered with readonly.html
+// when templates are in the context. Other requests are marked read-only,
+// for the templates to hide the editing links.
+func MakeReadOnlyMiddleware() Middleware {
+	return func(h Handler) Handler {
+		return HandlerFunc(func(w http.ResponseWriter, r *http.Request) (int, error) {
+			r = r.WithContext(context.WithValue(r.Context(), readOnlyKey, true))
+			if role, _ := requiredRole(r); role < RoleEditor {
+				return h.ServeHTTP(w, r)
+			}
+			t, ok := TemplateFromCtx(r.Context())
+			if !ok {
+				w.Header().Set("Content-Type", "text/plain")
+				w.WriteHeader(http.StatusForbidden)
+				w.Write([]byte("forbidden: the wiki is read-only"))
+				return http.StatusForbidden, nil
+			}
+			valid, _ := ValidURLFromCtx(r.Context())
+			v := &struct {
+				TemplateInfo
+				Path string
+			}{
+				TemplateInfo: newTemplateInfo(r, valid),
+				Path:         PagePathFromValidURL(valid),
+			}
+			w.WriteHeader(http.StatusForbidden)
+			err := t.ExecuteTemplate(w, "readonly.html", v)
+			return http.StatusForbidden, err
+		})
+	}
+}
@@ -0,0 +1,35 @@
+package mngr
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"runtime/debug"
+)
+
+// MakeRecoverMiddleware create a middleware turning the panics of the
+// handlers into 500 responses. The stack of the panic is written to out and
+// the panic is returned as error, to appear in the line of
+// MakeLogMiddleware. http.ErrAbortHandler is left to the server.
+func MakeRecoverMiddleware(out io.Writer) Middleware {
+	return func(h Handler) Handler {
+		return HandlerFunc(func(w http.ResponseWriter, r *http.Request) (code int, err error) {
+			defer func() {
+				v := recover()
+				if v == nil {
+					return
+				}
+				if v == http.ErrAbortHandler {
+					panic(v)
+				}
+				fmt.Fprintf(out, "panic serving %s %s (request %s): %v\n%s", r.Method, r.URL.Path, RequestIDFromCtx(r.Context()), v, debug.Stack())
+				w.Header().Set("Content-Type", "text/plain")
+				w.WriteHeader(http.StatusInternalServerError)
+				fmt.Fprintln(w, "internal server error")
+				writeRequestID(w, r)
+				code, err = http.StatusInternalServerError, fmt.Errorf("panic: %v", v)
+			}()
+			return h.ServeHTTP(w, r)
+		})
+	}
+}
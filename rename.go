@@ -0,0 +1,58 @@
+package mngr
+
+import (
+	"net/http"
+	"strings"
+)
+
+// referringPage is a page linking to a renamed file, with every link
+// occurrence.
+type referringPage struct {
+	Source string `json:"source"`
+	Links  []Link `json:"links"`
+}
+
+// groupBySource group link references by source page, keeping their order.
+func groupBySource(refs []LinkRef) []referringPage {
+	pages := []referringPage{}
+	for _, ref := range refs {
+		n := len(pages)
+		if n == 0 || pages[n-1].Source != ref.Source {
+			pages = append(pages, referringPage{Source: ref.Source})
+			n++
+		}
+		pages[n-1].Links = append(pages[n-1].Links, ref.Link)
+	}
+	return pages
+}
+
+// MakeRenamePreviewHandler return an handler listing, as JSON, the pages
+// linking to the requested page and every occurrence of those links. It
+// doesn't modify anything and is meant to be used before renaming a page.
+// The pages the user can't read are left out.
+func MakeRenamePreviewHandler(idx *LinkIndex) HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) (int, error) {
+		valid, _ := ValidURLFromCtx(r.Context())
+		p := strings.TrimPrefix(PagePathFromValidURL(valid), "/")
+		refs, err := idx.To(r.Context(), p)
+		if err != nil {
+			return 0, err
+		}
+		if readable := readFilter(r.Context()); readable != nil {
+			kept := refs[:0]
+			for _, ref := range refs {
+				if readable(ref.Source) {
+					kept = append(kept, ref)
+				}
+			}
+			refs = kept
+		}
+		return writeJSON(w, http.StatusOK, struct {
+			Path  string          `json:"path"`
+			Pages []referringPage `json:"pages"`
+		}{
+			Path:  p,
+			Pages: groupBySource(refs),
+		})
+	}
+}
@@ -0,0 +1,80 @@
+package mngr
+
+import (
+	"context"
+	"html/template"
+	"time"
+
+	"github.com/russross/blackfriday"
+)
+
+// markdownExts contains the extensions of files rendered as Markdown by a
+// sanitized view, other text files are displayed as is.
+var markdownExts = map[string]bool{
+	"":          true,
+	".md":       true,
+	".markdown": true,
+}
+
+// safeHTMLFlags are the renderer flags of blackfriday.MarkdownCommon plus
+// the ones dropping raw HTML, styles and links with unsafe protocols.
+const safeHTMLFlags = blackfriday.HTML_USE_XHTML |
+	blackfriday.HTML_USE_SMARTYPANTS |
+	blackfriday.HTML_SMARTYPANTS_FRACTIONS |
+	blackfriday.HTML_SMARTYPANTS_DASHES |
+	blackfriday.HTML_SMARTYPANTS_LATEX_DASHES |
+	blackfriday.HTML_SKIP_HTML |
+	blackfriday.HTML_SKIP_STYLE |
+	blackfriday.HTML_SAFELINK |
+	blackfriday.HTML_NOFOLLOW_LINKS |
+	blackfriday.HTML_NOREFERRER_LINKS
+
+// commonExtensions are the extensions used by blackfriday.MarkdownCommon.
+const commonExtensions = blackfriday.EXTENSION_NO_INTRA_EMPHASIS |
+	blackfriday.EXTENSION_TABLES |
+	blackfriday.EXTENSION_FENCED_CODE |
+	blackfriday.EXTENSION_AUTOLINK |
+	blackfriday.EXTENSION_STRIKETHROUGH |
+	blackfriday.EXTENSION_SPACE_HEADERS |
+	blackfriday.EXTENSION_HEADER_IDS |
+	blackfriday.EXTENSION_BACKSLASH_LINE_BREAK |
+	blackfriday.EXTENSION_DEFINITION_LISTS
+
+// markdownSafe convert a Markdown body to HTML like MarkdownCommon, without
+// the raw HTML it may contain.
+func markdownSafe(body []byte) []byte {
+	renderer := blackfriday.HtmlRenderer(safeHTMLFlags, "", "")
+	return blackfriday.Markdown(body, renderer, commonExtensions)
+}
+
+// renderPlain escape a text body and wrap it in a pre element.
+func renderPlain(body []byte) template.HTML {
+	return template.HTML("<pre>" + template.HTMLEscapeString(string(body)) + "</pre>")
+}
+
+// renderMarkdown convert a Markdown body to HTML, giving up after timeout.
+// A timeout of 0 or less means no limit. The renderer can't be interrupted:
+// on timeout it keeps running in the background and its result is dropped.
+// When safe is set, raw HTML and unsafe links are removed from the output.
+func renderMarkdown(ctx context.Context, body []byte, timeout time.Duration, safe bool) (template.HTML, error) {
+	convert := blackfriday.MarkdownCommon
+	if safe {
+		convert = markdownSafe
+	}
+	if timeout <= 0 {
+		return template.HTML(convert(body)), nil
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	// The channel is buffered so an abandoned render can always complete.
+	done := make(chan []byte, 1)
+	go func() {
+		done <- convert(body)
+	}()
+	select {
+	case out := <-done:
+		return template.HTML(out), nil
+	case <-ctx.Done():
+		return "", ctx.Err()
+	}
+}
@@ -0,0 +1,60 @@
+package mngr
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+type requestIDCtxKey int
+
+var requestIDKey = requestIDCtxKey(0)
+
+// RequestIDHeader is the response header carrying the request ID.
+const RequestIDHeader = "X-Request-ID"
+
+// RequestIDFromCtx extract the ID given to a request by
+// MakeRequestIDMiddleware or MakeLogMiddleware from a context. It return an
+// empty string when there is none.
+func RequestIDFromCtx(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey).(string)
+	return id
+}
+
+// withRequestID give an unique ID to r, unless it already has one, and
+// send it in the RequestIDHeader of the response.
+func withRequestID(w http.ResponseWriter, r *http.Request) *http.Request {
+	if RequestIDFromCtx(r.Context()) != "" {
+		return r
+	}
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return r
+	}
+	id := hex.EncodeToString(b)
+	w.Header().Set(RequestIDHeader, id)
+	return r.WithContext(context.WithValue(r.Context(), requestIDKey, id))
+}
+
+// writeRequestID append the ID of r to an error response written to w, so
+// users can report it.
+func writeRequestID(w io.Writer, r *http.Request) {
+	if id := RequestIDFromCtx(r.Context()); id != "" {
+		fmt.Fprintln(w, "request id:", id)
+	}
+}
+
+// MakeRequestIDMiddleware create a middleware giving every request an
+// unique ID, added to the request's context and sent in the RequestIDHeader
+// of the response. MakeLogMiddleware does it too, logging the ID with the
+// request.
+func MakeRequestIDMiddleware() Middleware {
+	return func(h Handler) Handler {
+		return HandlerFunc(func(w http.ResponseWriter, r *http.Request) (int, error) {
+			return h.ServeHTTP(w, withRequestID(w, r))
+		})
+	}
+}
@@ -0,0 +1,46 @@
+package mngr
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"syscall"
+	"time"
+)
+
+// RetryPolicy configure how filesystem operations are retried
+// when they fail with a transient error.
+type RetryPolicy struct {
+	// Attempts is the total number of attempts, 1 or less disables retries.
+	Attempts int
+	// Backoff is the delay before the first retry, doubled after each retry.
+	Backoff time.Duration
+	// Log receives a line for every retry, when not nil.
+	Log io.Writer
+}
+
+// FSRetry is the policy used by DirStore.
+var FSRetry = RetryPolicy{Attempts: 3, Backoff: 10 * time.Millisecond}
+
+// isTransient report whether err is worth retrying. Logical errors like
+// not found or permission denied are never retried.
+func isTransient(err error) bool {
+	return errors.Is(err, syscall.EINTR) || errors.Is(err, syscall.EAGAIN)
+}
+
+// do call fn until it succeed, fail with a non transient error or the
+// attempts are exhausted. op names the operation in the retry logs.
+func (p RetryPolicy) do(op string, fn func() error) error {
+	delay := p.Backoff
+	for attempt := 1; ; attempt++ {
+		err := fn()
+		if err == nil || attempt >= p.Attempts || !isTransient(err) {
+			return err
+		}
+		if p.Log != nil {
+			fmt.Fprintf(p.Log, "retrying %s after attempt %d: %v\n", op, attempt, err)
+		}
+		time.Sleep(delay)
+		delay *= 2
+	}
+}
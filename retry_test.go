@@ -0,0 +1,62 @@
+package mngr
+
+import (
+	"bytes"
+	"os"
+	"strings"
+	"syscall"
+	"testing"
+)
+
+// flakyOp return the errors of errs in turn, then nil, counting its calls.
+func flakyOp(calls *int, errs ...error) func() error {
+	return func() error {
+		*calls++
+		if *calls <= len(errs) {
+			return errs[*calls-1]
+		}
+		return nil
+	}
+}
+
+func TestRetryTransient(t *testing.T) {
+	var log bytes.Buffer
+	p := FSRetry
+	p.Log = &log
+	calls := 0
+	eintr := &os.PathError{Op: "write", Path: "a.md", Err: syscall.EINTR}
+	if err := p.do("write a.md", flakyOp(&calls, eintr, syscall.EAGAIN)); err != nil {
+		t.Fatalf("do() = %v, want success after the retries", err)
+	}
+	if calls != 3 {
+		t.Errorf("called %d times, want 3", calls)
+	}
+	if n := strings.Count(log.String(), "retrying write a.md"); n != 2 {
+		t.Errorf("logged %d retries, want 2:\n%s", n, log.String())
+	}
+}
+
+func TestRetryExhausted(t *testing.T) {
+	p := RetryPolicy{Attempts: 2}
+	calls := 0
+	err := p.do("read a.md", flakyOp(&calls, syscall.EAGAIN, syscall.EAGAIN, syscall.EAGAIN))
+	if err != syscall.EAGAIN {
+		t.Errorf("do() = %v, want EAGAIN", err)
+	}
+	if calls != 2 {
+		t.Errorf("called %d times, want 2", calls)
+	}
+}
+
+func TestRetryLogicalErrors(t *testing.T) {
+	for _, errno := range []syscall.Errno{syscall.ENOENT, syscall.EACCES} {
+		calls := 0
+		want := &os.PathError{Op: "open", Path: "a.md", Err: errno}
+		if err := FSRetry.do("open a.md", flakyOp(&calls, want)); err != want {
+			t.Errorf("do() = %v, want %v", err, want)
+		}
+		if calls != 1 {
+			t.Errorf("%v retried: called %d times, want 1", errno, calls)
+		}
+	}
+}
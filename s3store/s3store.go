@@ -0,0 +1,235 @@
+// Package s3store implements a mngr.Store on top of an S3 compatible
+// object storage.
+//
+// Buckets have no folders: a file is an object whose key is its path and a
+// folder is emulated with an empty object whose key is the folder path
+// followed by a slash. Folders containing files are listed even when their
+// marker object is missing, so buckets filled by other tools can be served.
+package s3store
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"mime"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/aitva/mngr"
+	"github.com/minio/minio-go/v7"
+)
+
+var (
+	_ mngr.Store        = (*Store)(nil)
+	_ mngr.ContextStore = (*Store)(nil)
+)
+
+// Store is a mngr.Store keeping files as objects of a bucket.
+type Store struct {
+	client *minio.Client
+	bucket string
+	// ctx cancels the requests to the bucket, when set.
+	ctx context.Context
+}
+
+// New create a Store saving files in bucket through client.
+func New(client *minio.Client, bucket string) *Store {
+	return &Store{client: client, bucket: bucket}
+}
+
+// WithContext implements mngr.ContextStore: the returned Store stops its
+// requests when ctx is done.
+func (s *Store) WithContext(ctx context.Context) mngr.Store {
+	return &Store{client: s.client, bucket: s.bucket, ctx: ctx}
+}
+
+// context return the context of the requests of s.
+func (s *Store) context() context.Context {
+	if s.ctx == nil {
+		return context.Background()
+	}
+	return s.ctx
+}
+
+// key return the object key of name, without leading slash.
+func key(name string) string {
+	return strings.TrimPrefix(path.Clean("/"+name), "/")
+}
+
+// pathError convert an error returned by the client to an *os.PathError
+// recognized by os.IsNotExist and os.IsPermission.
+func pathError(op, name string, err error) error {
+	switch minio.ToErrorResponse(err).Code {
+	case "NoSuchKey", "NoSuchBucket":
+		err = os.ErrNotExist
+	case "AccessDenied":
+		err = os.ErrPermission
+	}
+	return &os.PathError{Op: op, Path: name, Err: err}
+}
+
+// fileInfo implements os.FileInfo for objects and emulated folders.
+type fileInfo struct {
+	name    string
+	size    int64
+	modTime time.Time
+	dir     bool
+}
+
+func (fi *fileInfo) Name() string       { return fi.name }
+func (fi *fileInfo) Size() int64        { return fi.size }
+func (fi *fileInfo) ModTime() time.Time { return fi.modTime }
+func (fi *fileInfo) IsDir() bool        { return fi.dir }
+func (fi *fileInfo) Sys() interface{}   { return nil }
+
+func (fi *fileInfo) Mode() os.FileMode {
+	if fi.dir {
+		return os.ModeDir | 0700
+	}
+	return 0600
+}
+
+// Read implements mngr.Store.
+func (s *Store) Read(name string) ([]byte, error) {
+	obj, err := s.client.GetObject(s.context(), s.bucket, key(name), minio.GetObjectOptions{})
+	if err != nil {
+		return nil, pathError("read", name, err)
+	}
+	defer obj.Close()
+	body, err := ioutil.ReadAll(obj)
+	if err != nil {
+		return nil, pathError("read", name, err)
+	}
+	return body, nil
+}
+
+// Write implements mngr.Store.
+func (s *Store) Write(name string, body []byte) error {
+	k := key(name)
+	opts := minio.PutObjectOptions{ContentType: mime.TypeByExtension(path.Ext(k))}
+	_, err := s.client.PutObject(s.context(), s.bucket, k, bytes.NewReader(body), int64(len(body)), opts)
+	if err != nil {
+		return pathError("write", name, err)
+	}
+	return nil
+}
+
+// list return the objects and folders found under the folder key k,
+// recursively when recursive is set. The folder marker is excluded.
+func (s *Store) list(k string, recursive bool) ([]minio.ObjectInfo, error) {
+	prefix := ""
+	if k != "" {
+		prefix = k + "/"
+	}
+	// Canceling ctx stops the listing.
+	ctx, cancel := context.WithCancel(s.context())
+	defer cancel()
+	var objects []minio.ObjectInfo
+	opts := minio.ListObjectsOptions{Prefix: prefix, Recursive: recursive}
+	for obj := range s.client.ListObjects(ctx, s.bucket, opts) {
+		if obj.Err != nil {
+			return nil, obj.Err
+		}
+		if obj.Key != prefix {
+			objects = append(objects, obj)
+		}
+	}
+	return objects, nil
+}
+
+// List implements mngr.Store.
+func (s *Store) List(name string) ([]os.FileInfo, error) {
+	k := key(name)
+	if _, err := s.Stat(name); err != nil {
+		return nil, err
+	}
+	objects, err := s.list(k, false)
+	if err != nil {
+		return nil, pathError("list", name, err)
+	}
+	fInfos := make([]os.FileInfo, 0, len(objects))
+	for _, obj := range objects {
+		fi := &fileInfo{
+			name:    path.Base(obj.Key),
+			size:    obj.Size,
+			modTime: obj.LastModified,
+			dir:     strings.HasSuffix(obj.Key, "/"),
+		}
+		fInfos = append(fInfos, fi)
+	}
+	sort.Slice(fInfos, func(i, j int) bool {
+		return fInfos[i].Name() < fInfos[j].Name()
+	})
+	return fInfos, nil
+}
+
+// Mkdir implements mngr.Store.
+func (s *Store) Mkdir(name string) error {
+	if _, err := s.Stat(name); err == nil {
+		return &os.PathError{Op: "mkdir", Path: name, Err: os.ErrExist}
+	}
+	if fi, err := s.Stat(path.Dir(key(name))); err != nil || !fi.IsDir() {
+		return &os.PathError{Op: "mkdir", Path: name, Err: os.ErrNotExist}
+	}
+	_, err := s.client.PutObject(s.context(), s.bucket, key(name)+"/", bytes.NewReader(nil), 0, minio.PutObjectOptions{})
+	if err != nil {
+		return pathError("mkdir", name, err)
+	}
+	return nil
+}
+
+// Remove implements mngr.Store.
+func (s *Store) Remove(name string) error {
+	fi, err := s.Stat(name)
+	if err != nil {
+		return err
+	}
+	k := key(name)
+	if !fi.IsDir() {
+		if err := s.client.RemoveObject(s.context(), s.bucket, k, minio.RemoveObjectOptions{}); err != nil {
+			return pathError("remove", name, err)
+		}
+		return nil
+	}
+	objects, err := s.list(k, true)
+	if err != nil {
+		return pathError("remove", name, err)
+	}
+	for _, obj := range objects {
+		if err := s.client.RemoveObject(s.context(), s.bucket, obj.Key, minio.RemoveObjectOptions{}); err != nil {
+			return pathError("remove", name, err)
+		}
+	}
+	if err := s.client.RemoveObject(s.context(), s.bucket, k+"/", minio.RemoveObjectOptions{}); err != nil {
+		return pathError("remove", name, err)
+	}
+	return nil
+}
+
+// Stat implements mngr.Store.
+func (s *Store) Stat(name string) (os.FileInfo, error) {
+	k := key(name)
+	if k == "" {
+		return &fileInfo{name: "/", dir: true}, nil
+	}
+	obj, err := s.client.StatObject(s.context(), s.bucket, k, minio.StatObjectOptions{})
+	if err == nil {
+		return &fileInfo{name: path.Base(k), size: obj.Size, modTime: obj.LastModified}, nil
+	}
+	if minio.ToErrorResponse(err).Code != "NoSuchKey" {
+		return nil, pathError("stat", name, err)
+	}
+	// A folder exists when it has a marker or contains anything.
+	ctx, cancel := context.WithCancel(s.context())
+	defer cancel()
+	for obj := range s.client.ListObjects(ctx, s.bucket, minio.ListObjectsOptions{Prefix: k + "/"}) {
+		if obj.Err != nil {
+			return nil, pathError("stat", name, obj.Err)
+		}
+		return &fileInfo{name: path.Base(k), modTime: obj.LastModified, dir: true}, nil
+	}
+	return nil, &os.PathError{Op: "stat", Path: name, Err: os.ErrNotExist}
+}
@@ -0,0 +1,298 @@
+package mngr
+
+import (
+	"context"
+	"math"
+	"net/http"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// SearchResult is a page matching a search.
+type SearchResult struct {
+	Path    string
+	Title   string
+	Score   float64
+	Snippet string
+}
+
+// searchDoc is an indexed page.
+type searchDoc struct {
+	title string
+	body  string
+	// terms count the occurrences of each word of the page.
+	terms map[string]int
+	words int
+}
+
+// SearchIndex is a full-text index of the text pages of a Store. It is
+// kept in memory: Build fills it, Update refresh a single page and
+// Invalidate make the next search rebuild it.
+type SearchIndex struct {
+	store   Store
+	workers int
+
+	mu    sync.RWMutex
+	docs  map[string]*searchDoc
+	built bool
+	// ready is set once the index was built, it isn't reset by Invalidate.
+	ready bool
+	// gen counts the changes a running build may have missed, it only
+	// publishes its pages when gen didn't change.
+	gen uint64
+	// building is closed when the running build, if any, ends.
+	building chan struct{}
+}
+
+// NewSearchIndex create an empty SearchIndex over s. Up to workers files
+// are read in parallel when building the index.
+func NewSearchIndex(s Store, workers int) *SearchIndex {
+	return &SearchIndex{store: s, workers: workers}
+}
+
+// indexKey normalize the path of a page.
+func indexKey(p string) string {
+	return strings.TrimPrefix(path.Clean("/"+p), "/")
+}
+
+func newSearchDoc(body []byte) *searchDoc {
+	doc := &searchDoc{title: PageTitle(body), body: string(body), terms: make(map[string]int)}
+	for _, w := range tokenize(body) {
+		doc.terms[w]++
+		doc.words++
+	}
+	return doc
+}
+
+// Build index every text page of the store, replacing the current index.
+// A single build runs at a time, the concurrent calls wait for it. A build
+// during which the index was invalidated is discarded and started again.
+func (idx *SearchIndex) Build(ctx context.Context) error {
+	for {
+		idx.mu.Lock()
+		if running := idx.building; running != nil {
+			idx.mu.Unlock()
+			select {
+			case <-running:
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+			idx.mu.RLock()
+			built := idx.built
+			idx.mu.RUnlock()
+			if built {
+				return nil
+			}
+			continue
+		}
+		gen, done := idx.gen, make(chan struct{})
+		idx.building = done
+		idx.mu.Unlock()
+
+		docs := make(map[string]*searchDoc)
+		err := walkPages(ctx, idx.store, "", nil, idx.workers, func(p string, body []byte) error {
+			if isText(body) {
+				docs[indexKey(p)] = newSearchDoc(body)
+			}
+			return nil
+		})
+		idx.mu.Lock()
+		idx.building = nil
+		close(done)
+		fresh := err == nil && gen == idx.gen
+		if fresh {
+			idx.docs = docs
+			idx.built = true
+			idx.ready = true
+		}
+		idx.mu.Unlock()
+		if err != nil || fresh {
+			return err
+		}
+	}
+}
+
+// Ready report whether the index was built at least once.
+func (idx *SearchIndex) Ready() bool {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+	return idx.ready
+}
+
+// Update refresh the page p in the index, removing it when it no longer
+// exists or isn't a text page. A running build, which may have read p
+// before it changed, is started again.
+func (idx *SearchIndex) Update(p string) error {
+	body, err := idx.store.Read(p)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	key := indexKey(p)
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	if idx.building != nil {
+		idx.gen++
+	}
+	if !idx.built {
+		return nil
+	}
+	if err != nil || !isText(body) {
+		delete(idx.docs, key)
+		return nil
+	}
+	idx.docs[key] = newSearchDoc(body)
+	return nil
+}
+
+// Invalidate drop the index, it will be rebuilt on the next search.
+func (idx *SearchIndex) Invalidate() {
+	idx.mu.Lock()
+	idx.docs = nil
+	idx.built = false
+	idx.gen++
+	idx.mu.Unlock()
+}
+
+// snippet return the first line of body containing one of terms.
+func snippet(body string, terms []string) string {
+	for _, line := range strings.Split(body, "\n") {
+		lower := strings.ToLower(line)
+		for _, t := range terms {
+			if strings.Contains(lower, t) {
+				return strings.TrimSpace(line)
+			}
+		}
+	}
+	return ""
+}
+
+// Search return the pages containing every word of query, best match
+// first, building the index when needed. Pages are ranked by TF-IDF, words
+// found in the title of a page count double. At most limit results are
+// returned, 0 meaning no limit.
+func (idx *SearchIndex) Search(ctx context.Context, query string, limit int) ([]SearchResult, error) {
+	idx.mu.RLock()
+	built := idx.built
+	idx.mu.RUnlock()
+	if !built {
+		if err := idx.Build(ctx); err != nil {
+			return nil, err
+		}
+	}
+	terms := tokenize([]byte(query))
+	results := []SearchResult{}
+	if len(terms) == 0 {
+		return results, nil
+	}
+
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+	idf := make(map[string]float64, len(terms))
+	for _, t := range terms {
+		df := 0
+		for _, doc := range idx.docs {
+			if doc.terms[t] > 0 {
+				df++
+			}
+		}
+		idf[t] = math.Log(1 + float64(len(idx.docs))/float64(df+1))
+	}
+	for p, doc := range idx.docs {
+		score := 0.0
+		title := strings.ToLower(doc.title)
+		for _, t := range terms {
+			n := doc.terms[t]
+			if n == 0 {
+				score = 0
+				break
+			}
+			tf := float64(n) / float64(doc.words)
+			if strings.Contains(title, t) {
+				tf *= 2
+			}
+			score += tf * idf[t]
+		}
+		if score > 0 {
+			results = append(results, SearchResult{Path: p, Title: doc.title, Score: score, Snippet: snippet(doc.body, terms)})
+		}
+	}
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].Score != results[j].Score {
+			return results[i].Score > results[j].Score
+		}
+		return results[i].Path < results[j].Path
+	})
+	if limit > 0 && len(results) > limit {
+		results = results[:limit]
+	}
+	return results, nil
+}
+
+// MakeSearchHandler return an handler rendering, with search.html, the
+// pages matching the 'q' query value. At most limit results are shown,
+// among the pages the ACL of the request, if any, lets the user read.
+func MakeSearchHandler(idx *SearchIndex, limit int) ContextHandlerFunc {
+	return func(ctx context.Context, w http.ResponseWriter, r *http.Request) (int, error) {
+		q := r.URL.Query().Get("q")
+		readable := readFilter(ctx)
+		n := limit
+		if readable != nil {
+			n = 0
+		}
+		results, err := idx.Search(ctx, q, n)
+		if err != nil {
+			return 0, err
+		}
+		if readable != nil {
+			kept := results[:0]
+			for _, res := range results {
+				if readable(res.Path) {
+					kept = append(kept, res)
+				}
+			}
+			results = kept
+			if limit > 0 && len(results) > limit {
+				results = results[:limit]
+			}
+		}
+		v := &struct {
+			TemplateInfo
+			Query   string
+			Results []SearchResult
+		}{
+			TemplateInfo: newTemplateInfo(r, ValidURL{Action: "search"}),
+			Query:        q,
+			Results:      results,
+		}
+		t, _ := TemplateFromCtx(ctx)
+		err = t.ExecuteTemplate(w, "search.html", v)
+		return 200, err
+	}
+}
+
+// MakeSearchIndexMiddleware create a middleware keeping idx up to date
+// with the changes made by the next Handler. When it succeeds, the page it
+// saved is re-indexed, other changes invalidate the index. The requests with
+// a safe method, like the GET of the forms, leave it untouched.
+func MakeSearchIndexMiddleware(idx *SearchIndex) Middleware {
+	return func(h Handler) Handler {
+		return HandlerFunc(func(w http.ResponseWriter, r *http.Request) (int, error) {
+			code, err := h.ServeHTTP(w, r)
+			if err != nil || code >= http.StatusBadRequest || safeMethods[r.Method] {
+				return code, err
+			}
+			valid, ok := ValidURLFromCtx(r.Context())
+			if ok && valid.Action == "save" && valid.Value != "" {
+				if uerr := idx.Update(PagePathFromValidURL(valid)); uerr != nil {
+					idx.Invalidate()
+				}
+				return code, err
+			}
+			idx.Invalidate()
+			return code, err
+		})
+	}
+}
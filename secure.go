@@ -0,0 +1,76 @@
+package mngr
+
+import (
+	"net/http"
+	"net/textproto"
+	"strconv"
+	"time"
+)
+
+// DefaultSecurityHeaders are the headers set by MakeSecurityMiddleware
+// unless overridden.
+var DefaultSecurityHeaders = map[string]string{
+	"Content-Security-Policy":    "default-src 'self'; img-src 'self' data: https:; object-src 'none'; base-uri 'self'; form-action 'self'; frame-ancestors 'none'",
+	"X-Content-Type-Options":     "nosniff",
+	"X-Frame-Options":            "DENY",
+	"Referrer-Policy":            "same-origin",
+	"Permissions-Policy":         "camera=(), microphone=(), geolocation=()",
+	"Cross-Origin-Opener-Policy": "same-origin",
+}
+
+// SecurityOption configure the middleware returned by MakeSecurityMiddleware.
+type SecurityOption func(*securityConfig)
+
+type securityConfig struct {
+	headers map[string]string
+	// hsts is the max-age of Strict-Transport-Security, disabled when 0.
+	hsts time.Duration
+}
+
+// SecurityHeader make the middleware set the header name to value, instead
+// of its default. An empty value disables the header.
+func SecurityHeader(name, value string) SecurityOption {
+	return func(c *securityConfig) {
+		c.headers[textproto.CanonicalMIMEHeaderKey(name)] = value
+	}
+}
+
+// SecurityHSTS make the middleware ask the browsers to only use HTTPS for
+// maxAge. The header is only sent on TLS connections.
+func SecurityHSTS(maxAge time.Duration) SecurityOption {
+	return func(c *securityConfig) {
+		c.hsts = maxAge
+	}
+}
+
+// MakeSecurityMiddleware create a middleware setting the security headers
+// of the responses, DefaultSecurityHeaders configured by opts. The headers
+// are set before calling the handler, which can override them; the policy
+// of MakeNonceMiddleware replaces the default Content-Security-Policy.
+func MakeSecurityMiddleware(opts ...SecurityOption) Middleware {
+	c := securityConfig{headers: make(map[string]string, len(DefaultSecurityHeaders))}
+	for name, value := range DefaultSecurityHeaders {
+		c.headers[name] = value
+	}
+	for _, opt := range opts {
+		opt(&c)
+	}
+	for name, value := range c.headers {
+		if value == "" {
+			delete(c.headers, name)
+		}
+	}
+	hsts := "max-age=" + strconv.Itoa(int(c.hsts/time.Second))
+	return func(h Handler) Handler {
+		return HandlerFunc(func(w http.ResponseWriter, r *http.Request) (int, error) {
+			header := w.Header()
+			for name, value := range c.headers {
+				header.Set(name, value)
+			}
+			if c.hsts > 0 && r.TLS != nil {
+				header.Set("Strict-Transport-Security", hsts)
+			}
+			return h.ServeHTTP(w, r)
+		})
+	}
+}
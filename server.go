@@ -0,0 +1,769 @@
+package mngr
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/fs"
+	"net"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/acme/autocert"
+)
+
+// ServerOption configure the Server returned by NewServer.
+type ServerOption func(*serverConfig)
+
+type serverConfig struct {
+	addr     string
+	tmplPath string
+	// tmplFS holds the templates in place of the tmplPath folder, when set.
+	tmplFS     fs.FS
+	staticPath string
+	store      Store
+	// stored adds the store to the requests' context.
+	stored   Middleware
+	logOut   io.Writer
+	logOpts  []LogOption
+	write    Middleware
+	read     Middleware
+	client   Middleware
+	sessions *Sessions
+	provider AuthProvider
+	acl      *ACL
+	readOnly bool
+	// rate is the requests per second allowed to every client, 0 when
+	// they aren't limited.
+	rate  float64
+	burst int
+	// trusted are the proxies whose clients are read from their headers.
+	trusted []*net.IPNet
+	// compress is the middleware compressing the responses.
+	compress Middleware
+	// theme is the folder of the theme, if any.
+	theme string
+	// timeout is the deadline of the requests, if any.
+	timeout time.Duration
+	// catalog translates the templates.
+	catalog *Catalog
+	// tmplOpts are the options of the template middleware.
+	tmplOpts []TemplateOption
+	// reload makes the templates compiled again when they change.
+	reload bool
+	// cache holds the Cache-Control headers of the routes.
+	cache      CachePolicy
+	checks     []HealthCheck
+	middleware []Middleware
+	workers    int
+	// maxUpload and maxUploadRequest limit the size of an uploaded file
+	// and of an upload request.
+	maxUpload        int64
+	maxUploadRequest int64
+	renderTimeout    time.Duration
+	searchLimit      int
+	// debounce is the window of the autosaves, 0 when saves are written
+	// immediately.
+	debounce time.Duration
+	// autocert gets the certificates from Let's Encrypt when set.
+	autocert *autocert.Manager
+	// redirectAddr is the address redirecting HTTP to HTTPS, if any.
+	redirectAddr string
+	base         string
+	// errorStatus chooses the code of the errors of the handlers.
+	errorStatus ErrorStatusFunc
+}
+
+// ServerAddr make the server listen on addr, ":8080" by default.
+func ServerAddr(addr string) ServerOption {
+	return func(c *serverConfig) {
+		c.addr = addr
+	}
+}
+
+// ServerTemplates make the templates of the folder path, "tmpl" by
+// default, override the DefaultTemplates of the same name.
+func ServerTemplates(path string) ServerOption {
+	return func(c *serverConfig) {
+		c.tmplPath = path
+	}
+}
+
+// ServerTemplateFS make the templates of fsys, in place of those of the
+// ServerTemplates folder, override the DefaultTemplates of the same name.
+func ServerTemplateFS(fsys fs.FS) ServerOption {
+	return func(c *serverConfig) {
+		c.tmplFS = fsys
+	}
+}
+
+// ServerTheme make the server use the theme of the folder dir: the
+// templates of its 'tmpl' folder and the static files of its 'static'
+// folder replace those of the same name, of ServerTemplates and ServerStatic
+// or of DefaultTemplates and DefaultStatic, and the catalogs of its 'locale'
+// folder those of DefaultLocales. A theme only holds the files it changes.
+func ServerTheme(dir string) ServerOption {
+	return func(c *serverConfig) {
+		c.theme = dir
+	}
+}
+
+// ServerRequestTimeout make the server give every request a deadline of d,
+// see MakeTimeoutMiddleware. WebDAV and the downloads of large files might
+// need longer than d.
+func ServerRequestTimeout(d time.Duration) ServerOption {
+	return func(c *serverConfig) {
+		c.timeout = d
+	}
+}
+
+// ServerCatalog make the server translate its templates with c, negotiated
+// with the clients, see MakeLanguageMiddleware. By default, the catalog
+// is DefaultLocales, where the 'locale' folder of the theme replaces the
+// languages it holds.
+func ServerCatalog(c *Catalog) ServerOption {
+	return func(sc *serverConfig) {
+		sc.catalog = c
+	}
+}
+
+// ServerTemplateFuncs make the functions of funcs available to the
+// templates, see TemplateFuncs.
+func ServerTemplateFuncs(funcs map[string]interface{}) ServerOption {
+	return func(c *serverConfig) {
+		c.tmplOpts = append(c.tmplOpts, TemplateFuncs(funcs))
+	}
+}
+
+// ServerTemplateReload make the server compile its templates again when
+// they change, see MakeReloadTemplateMiddleware. It is meant for the
+// development of themes.
+func ServerTemplateReload() ServerOption {
+	return func(c *serverConfig) {
+		c.reload = true
+	}
+}
+
+// ServerStatic make the server serve the static files of path under
+// /static/, "static" by default, in place of the DefaultStatic files of
+// the same name.
+func ServerStatic(path string) ServerOption {
+	return func(c *serverConfig) {
+		c.staticPath = path
+	}
+}
+
+// ServerStore make the server keep the pages in s instead of a DirStore of
+// its data path. stored adds the store to the requests, like
+// MakeStoreMiddleware(s) which is used when it is nil.
+func ServerStore(s Store, stored Middleware) ServerOption {
+	return func(c *serverConfig) {
+		c.store, c.stored = s, stored
+	}
+}
+
+// ServerLog make the server log the requests to out with opts, see
+// MakeLogMiddleware. The requests are logged to os.Stdout by default.
+func ServerLog(out io.Writer, opts ...LogOption) ServerOption {
+	return func(c *serverConfig) {
+		c.logOut, c.logOpts = out, opts
+	}
+}
+
+// ServerAuth make the server authenticate the requests: write protects the
+// routes modifying the wiki, read the other ones and client the routes used
+// by scripts and native clients, the API and WebDAV.
+func ServerAuth(write, read, client Middleware) ServerOption {
+	return func(c *serverConfig) {
+		c.write, c.read, c.client = write, read, client
+	}
+}
+
+// ServerSessions make the server serve the /login and /logout pages of s.
+// When p isn't nil, the login is delegated to p.
+func ServerSessions(s *Sessions, p AuthProvider) ServerOption {
+	return func(c *serverConfig) {
+		c.sessions, c.provider = s, p
+	}
+}
+
+// ServerACL make the server check the roles granted by a.
+func ServerACL(a *ACL) ServerOption {
+	return func(c *serverConfig) {
+		c.acl = a
+	}
+}
+
+// ServerReadOnly make the server reject the requests modifying the wiki,
+// see MakeReadOnlyMiddleware.
+func ServerReadOnly() ServerOption {
+	return func(c *serverConfig) {
+		c.readOnly = true
+	}
+}
+
+// ServerRateLimit make the server limit the requests of every client, see
+// MakeRateLimitMiddleware.
+func ServerRateLimit(rate float64, burst int) ServerOption {
+	return func(c *serverConfig) {
+		c.rate, c.burst = rate, burst
+	}
+}
+
+// ServerCompress make the server compress the responses of types with
+// level, see MakeCompressMiddleware.
+func ServerCompress(level int, types ...string) ServerOption {
+	return func(c *serverConfig) {
+		c.compress = MakeCompressMiddleware(level, types...)
+	}
+}
+
+// ServerCachePolicy make the server send the Cache-Control headers of p,
+// instead of those of DefaultCachePolicy.
+func ServerCachePolicy(p CachePolicy) ServerOption {
+	return func(c *serverConfig) {
+		c.cache = p
+	}
+}
+
+// ServerTrustedProxies make the server identify the clients of the proxies
+// in trusted by X-Forwarded-For or X-Real-IP, for the logs and the rate
+// limit. The peers of a unix socket are always trusted.
+func ServerTrustedProxies(trusted []*net.IPNet) ServerOption {
+	return func(c *serverConfig) {
+		c.trusted = trusted
+	}
+}
+
+// ServerHealthCheck make the readiness probe of the server run checks too,
+// after those of the store and of the search index.
+func ServerHealthCheck(checks ...HealthCheck) ServerOption {
+	return func(c *serverConfig) {
+		c.checks = append(c.checks, checks...)
+	}
+}
+
+// ServerMiddleware make the server pass every request through mw, after the
+// logging and before the routing. Tracing is plugged this way.
+func ServerMiddleware(mw Middleware) ServerOption {
+	return func(c *serverConfig) {
+		c.middleware = append(c.middleware, mw)
+	}
+}
+
+// ServerWorkers make the tree walks of the server read up to n files in
+// parallel, 4 by default.
+func ServerWorkers(n int) ServerOption {
+	return func(c *serverConfig) {
+		c.workers = n
+	}
+}
+
+// ServerMaxUpload make the server reject the uploaded files larger than
+// size and the upload requests larger than requestSize.
+func ServerMaxUpload(size, requestSize int64) ServerOption {
+	return func(c *serverConfig) {
+		c.maxUpload, c.maxUploadRequest = size, requestSize
+	}
+}
+
+// ServerErrorStatus make the server choose the code of the errors returned
+// by the handlers with status, DefaultErrorStatus by default. The code of
+// an HTTPError is kept, see MakeErrorMiddleware.
+func ServerErrorStatus(status ErrorStatusFunc) ServerOption {
+	return func(c *serverConfig) {
+		c.errorStatus = status
+	}
+}
+
+// ServerSaveDebounce make the server coalesce the autosaves of a page
+// happening within window, see MakeSaveHandler. The pending saves are
+// written by Shutdown.
+func ServerSaveDebounce(window time.Duration) ServerOption {
+	return func(c *serverConfig) {
+		c.debounce = window
+	}
+}
+
+// ServerAutocert make ListenAndServeTLS get the certificates of hosts from
+// Let's Encrypt, accepting its terms of service, and keep them in the
+// folder cacheDir. The challenges are answered on the TLS address, which
+// must be reachable on port 443, and on the address of ServerRedirectHTTP
+// if any.
+func ServerAutocert(cacheDir string, hosts ...string) ServerOption {
+	return func(c *serverConfig) {
+		c.autocert = &autocert.Manager{
+			Prompt:     autocert.AcceptTOS,
+			Cache:      autocert.DirCache(cacheDir),
+			HostPolicy: autocert.HostWhitelist(hosts...),
+		}
+	}
+}
+
+// ServerRedirectHTTP make ListenAndServeTLS listen on addr too, like ":80",
+// redirecting the HTTP requests to HTTPS.
+func ServerRedirectHTTP(addr string) ServerOption {
+	return func(c *serverConfig) {
+		c.redirectAddr = addr
+	}
+}
+
+// ServerBasePath make the server serve the wiki under base, like "/wiki",
+// for a reverse proxy forwarding this prefix. The links and the redirects
+// are prefixed with base, see MakeBasePathMiddleware.
+func ServerBasePath(base string) ServerOption {
+	return func(c *serverConfig) {
+		c.base = cleanBasePath(base)
+	}
+}
+
+// Server serve a wiki: it wires the templates, the middlewares and the
+// handlers of mngr into an http.Handler.
+type Server struct {
+	config  serverConfig
+	store   Store
+	links   *LinkIndex
+	search  *SearchIndex
+	metrics *Metrics
+	mux     *http.ServeMux
+	// wrap is the chain shared by every route, logging the requests.
+	wrap func(h Handler) http.HandlerFunc
+	// guard protects the routes, client and clientGuard those of
+	// HandleClient.
+	client, guard, clientGuard Middleware
+	saves                      *SaveDebouncer
+
+	mu      sync.Mutex
+	servers []*http.Server
+	cancel  context.CancelFunc
+	// built is closed when the search index build started by buildSearch
+	// ends.
+	built chan struct{}
+}
+
+// identity is a middleware doing nothing.
+func identity(h Handler) Handler {
+	return h
+}
+
+// MakeRouter return an http.Handler serving the wiki of the folder
+// dataPath with every route of the Server, each with its validation and
+// template middlewares, rendered with the templates of fsys, or the
+// DefaultTemplates when nil. The options configure the routes like those
+// of NewServer, the listening ones are ignored. The search index is built
+// in background, like by ListenAndServe. It panics when the templates can't
+// be loaded.
+func MakeRouter(dataPath string, templates fs.FS, opts ...ServerOption) http.Handler {
+	if templates == nil {
+		templates = DefaultTemplates
+	}
+	opts = append([]ServerOption{ServerTemplateFS(templates)}, opts...)
+	s := NewServer(dataPath, opts...)
+	s.buildSearch()
+	return s
+}
+
+// NewServer create a Server serving the pages of the folder dataPath,
+// configured by opts. It panics when the templates can't be loaded.
+func NewServer(dataPath string, opts ...ServerOption) *Server {
+	c := serverConfig{
+		addr:             ":8080",
+		tmplPath:         "tmpl",
+		staticPath:       "static",
+		logOut:           os.Stdout,
+		write:            identity,
+		read:             identity,
+		compress:         identity,
+		cache:            DefaultCachePolicy,
+		workers:          4,
+		maxUpload:        10 << 20,
+		maxUploadRequest: 50 << 20,
+		renderTimeout:    5 * time.Second,
+		searchLimit:      50,
+		errorStatus:      DefaultErrorStatus,
+	}
+	for _, opt := range opts {
+		opt(&c)
+	}
+	if c.store == nil {
+		c.store = DirStore(dataPath)
+		c.checks = append(c.checks, DirCheck(dataPath))
+	}
+	if c.stored == nil {
+		c.stored = MakeStoreMiddleware(c.store)
+	}
+	if c.client == nil {
+		c.client = c.write
+	}
+	if c.catalog == nil {
+		locales := DefaultLocales
+		if c.theme != "" {
+			locales = OverlayFS(os.DirFS(filepath.Join(c.theme, "locale")), locales)
+		}
+		c.catalog = mustCatalog(locales)
+	}
+	c.tmplOpts = append([]TemplateOption{TemplateCatalog(c.catalog)}, c.tmplOpts...)
+	s := &Server{
+		config:  c,
+		store:   c.store,
+		links:   NewLinkIndex(c.store, time.Minute, c.workers),
+		search:  NewSearchIndex(c.store, c.workers),
+		metrics: NewMetrics(),
+		mux:     http.NewServeMux(),
+		client:  c.client,
+	}
+	if c.debounce > 0 {
+		s.saves = NewSaveDebouncer(c.debounce, os.Stderr)
+		s.saves.OnSave(func(path string) {
+			s.links.Invalidate()
+			if err := s.search.Update(path); err != nil {
+				s.search.Invalidate()
+			}
+		})
+	}
+
+	logOpts := append([]LogOption{LogTrustedProxies(c.trusted)}, c.logOpts...)
+	logger := MakeLogMiddleware(c.logOut, logOpts...)
+	recovery := MakeRecoverMiddleware(os.Stderr)
+	measure := MakeMetricsMiddleware(s.metrics)
+	counters := MakeDebugCountersMiddleware()
+	secure := MakeSecurityMiddleware()
+	based := MakeBasePathMiddleware(c.base)
+	lang := MakeLanguageMiddleware(c.catalog)
+	timeout := MakeTimeoutMiddleware(c.timeout)
+	limit := identity
+	if c.rate > 0 {
+		limit = MakeRateLimitMiddleware(c.rate, c.burst, c.trusted)
+	}
+	common := Chain(append([]Middleware{recovery, based, measure, counters, limit, timeout, secure, lang, c.compress}, c.middleware...)...)
+	s.wrap = func(h Handler) http.HandlerFunc {
+		return logger(common(h))
+	}
+	s.guard, s.clientGuard = identity, identity
+	if c.acl != nil {
+		s.guard, s.clientGuard = MakeACLMiddleware(c.acl), withACL(c.acl)
+	}
+	if c.readOnly {
+		s.guard = Chain(s.guard, MakeReadOnlyMiddleware())
+		s.clientGuard = Chain(s.clientGuard, MakeReadOnlyMiddleware())
+	}
+	s.routes()
+	return s
+}
+
+// indexHandler redirect to the root folder. The other paths, matching no
+// route, are answered with 404.
+func indexHandler(w http.ResponseWriter, r *http.Request) (int, error) {
+	if r.URL.Path != "/" {
+		w.Header().Set("Content-Type", "text/plain")
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte("not found"))
+		return http.StatusNotFound, nil
+	}
+	redirect(w, r, "/list/", http.StatusFound)
+	return http.StatusFound, nil
+}
+
+// routes register the handlers of s.
+func (s *Server) routes() {
+	c := &s.config
+	store, stored, log, acl := s.store, c.stored, s.wrap, s.guard
+	errs := MakeErrorMiddleware(c.errorStatus)
+	templates := c.tmplFS
+	if templates == nil {
+		templates = os.DirFS(c.tmplPath)
+	}
+	templates = OverlayFS(templates, DefaultTemplates)
+	assets := OverlayFS(os.DirFS(c.staticPath), DefaultStatic)
+	if c.theme != "" {
+		templates = OverlayFS(os.DirFS(filepath.Join(c.theme, "tmpl")), templates)
+		assets = OverlayFS(os.DirFS(filepath.Join(c.theme, "static")), assets)
+	}
+	load := MakeFSTemplateMiddleware(templates, c.tmplOpts...)
+	if c.reload {
+		load = MakeReloadTemplateMiddleware(templates, c.tmplOpts...)
+	}
+	pages := MakeErrorPageMiddleware(c.errorStatus)
+	tmpl := Chain(load, pages)
+	csrf := MakeCSRFMiddleware()
+	edit := MakeCacheControlMiddleware(c.cache.Edit)
+	view := MakeCacheControlMiddleware(c.cache.View)
+	static := MakeCacheControlMiddleware(c.cache.Static)
+	auth := Chain(c.write, csrf, edit)
+	read := Chain(c.read, view)
+	valid := MakeValidURLMiddleware()
+	validFolder := MakeValidFolderMiddleware(store)
+	linksRefresh := MakeLinkIndexMiddleware(s.links)
+	searchRefresh := MakeSearchIndexMiddleware(s.search)
+	refresh := Chain(linksRefresh, searchRefresh)
+	workers := c.workers
+
+	viewOpts := []ViewOption{
+		ViewRenderTimeout(c.renderTimeout),
+		ViewHeadingAnchors(DefaultSlugOptions),
+		ViewSanitized(),
+	}
+	metadataOpts := MakeOptionsMiddleware("List the pages of a folder missing required front matter keys.", http.MethodGet)
+	assetsOpts := MakeOptionsMiddleware("List the broken relative asset references of a page.", http.MethodGet)
+	referencesOpts := MakeOptionsMiddleware("List the pages linking to a page.", http.MethodGet)
+
+	m := s.mux
+	get := MakeMethodMiddleware(http.MethodGet)
+	form := MakeMethodMiddleware(http.MethodGet, http.MethodPost)
+	put := MakeMethodMiddleware(http.MethodPost, http.MethodPut)
+	post := MakeMethodMiddleware(http.MethodPost)
+	remove := MakeMethodMiddleware(http.MethodGet, http.MethodPost, http.MethodDelete)
+	m.Handle("/", log(read(acl(tmpl(HandlerFunc(indexHandler))))))
+	m.Handle("/list/", log(errs(get(read(tmpl(validFolder(acl(MakeListHandler(store, ListCompressAbove(500))))))))))
+	m.Handle("/view/", log(errs(get(read(stored(tmpl(valid(acl(MakeViewHandler(viewOpts...))))))))))
+	m.Handle("/edit/", log(errs(get(auth(stored(tmpl(valid(acl(HandlerFunc(EditHandler))))))))))
+	m.Handle("/save/", log(errs(put(auth(stored(tmpl(valid(acl(refresh(MakeSaveHandler(s.saves)))))))))))
+	m.Handle("/folder/", log(errs(post(auth(stored(tmpl(valid(acl(HandlerFunc(FolderHandler))))))))))
+	m.Handle("/new/", log(errs(form(auth(tmpl(valid(acl(MakeNewHandler()))))))))
+	m.Handle("/move/", log(errs(form(auth(stored(tmpl(valid(acl(refresh(HandlerFunc(MoveHandler)))))))))))
+	m.Handle("/copy/", log(errs(form(auth(stored(tmpl(valid(acl(refresh(HandlerFunc(CopyHandler)))))))))))
+	m.Handle("/upload/", log(errs(form(auth(stored(tmpl(validFolder(acl(refresh(MakeUploadHandler(c.maxUpload, c.maxUploadRequest)))))))))))
+	m.Handle("/download/", log(errs(get(read(stored(valid(acl(HandlerFunc(DownloadHandler)))))))))
+	m.Handle("/history/", log(errs(get(read(stored(tmpl(valid(acl(HandlerFunc(HistoryHandler))))))))))
+	m.Handle("/diff/", log(errs(get(read(stored(tmpl(valid(acl(HandlerFunc(DiffHandler))))))))))
+	m.Handle("/revert/", log(errs(form(auth(stored(tmpl(valid(acl(refresh(HandlerFunc(RevertHandler)))))))))))
+	m.Handle("/delete/", log(errs(remove(auth(stored(tmpl(valid(acl(refresh(HandlerFunc(DeleteHandler)))))))))))
+	m.Handle("/trash/", log(errs(form(auth(stored(tmpl(acl(refresh(HandlerFunc(TrashHandler))))))))))
+	m.Handle("/search", log(errs(get(read(acl(tmpl(MakeSearchHandler(s.search, c.searchLimit))))))))
+	m.Handle("/quickopen", log(errs(read(acl(MakeQuickOpenHandler(store, 10*time.Second, c.searchLimit))))))
+	m.Handle("/api/v1/", log(errs(c.client(csrf(edit(stored(MakeAPIHandler(acl, refresh))))))))
+	m.Handle("/index/", log(errs(get(read(tmpl(validFolder(acl(MakeSiteIndexHandler(store, 0)))))))))
+	m.Handle("/export/", log(errs(read(validFolder(acl(MakeExportHandler(store, workers)))))))
+	m.Handle("/metadata/", log(errs(read(metadataOpts(validFolder(acl(MakeMetadataAuditHandler(store, []string{"title"}, time.Minute, workers))))))))
+	m.Handle("/assets/", log(errs(read(assetsOpts(valid(acl(MakeBrokenAssetsHandler(store))))))))
+	m.Handle("/references/", log(errs(read(referencesOpts(valid(acl(MakeRenamePreviewHandler(s.links))))))))
+	m.Handle("/touch/", log(errs(auth(validFolder(acl(MakeTouchHandler(store, workers)))))))
+	m.Handle("/duplicates/", log(errs(read(validFolder(acl(MakeDuplicatesHandler(store)))))))
+	m.Handle("/archive/", log(errs(get(read(tmpl(validFolder(acl(MakeArchiveHandler(store, time.Minute, workers)))))))))
+	m.Handle("/similarity", log(errs(read(acl(MakeSimilarityHandler(store))))))
+	m.Handle("/convert/", log(errs(auth(validFolder(acl(MakeConvertHandler(store)))))))
+	m.Handle("/words/", log(errs(read(validFolder(acl(MakeWordsHandler(store, DefaultStopWords, time.Minute, workers)))))))
+	m.Handle("/external/", log(errs(read(validFolder(acl(MakeExternalLinksHandler(store, workers)))))))
+	m.Handle("/snapshot/", log(errs(auth(validFolder(acl(MakeSnapshotHandler(store)))))))
+	m.Handle("/snapshot-diff", log(errs(read(acl(MakeSnapshotDiffHandler(store))))))
+	m.Handle("/popular", log(errs(read(acl(MakePopularHandler(s.links))))))
+	m.Handle("/static/", log(static(MakeFSStaticHandler(assets, "/static/"))))
+	m.Handle("/metrics", log(c.client(MakeMetricsHandler(s.metrics))))
+
+	checks := append([]HealthCheck{StoreCheck(store), SearchCheck(s.search)}, c.checks...)
+	m.Handle("/healthz", log(MakeHealthHandler()))
+	m.Handle("/readyz", log(MakeReadyHandler(5*time.Second, checks...)))
+
+	if c.sessions != nil {
+		if c.provider != nil {
+			m.Handle("/login", log(errs(MakeProviderLoginHandler(c.provider))))
+			m.Handle("/login/callback", log(errs(MakeProviderCallbackHandler(c.sessions, c.provider))))
+		} else {
+			session := MakeSessionMiddleware(c.sessions)
+			m.Handle("/login", log(errs(csrf(session(tmpl(MakeLoginHandler(c.sessions)))))))
+		}
+		m.Handle("/logout", log(errs(csrf(MakeLogoutHandler(c.sessions)))))
+	}
+}
+
+// Store return the Store holding the pages of s.
+func (s *Server) Store() Store {
+	return s.store
+}
+
+// Invalidate drop the indexes of the pages, to be called after the store
+// was modified without going through the handlers of s.
+func (s *Server) Invalidate() {
+	s.links.Invalidate()
+	s.search.Invalidate()
+}
+
+// HandleClient register h for pattern as a route used by scripts and
+// native clients, like WebDAV: the requests are authenticated like the API.
+// The ACL, if any, is only added to their context, see ACLFromCtx: h must
+// check the role of the paths it serves.
+func (s *Server) HandleClient(pattern string, h http.Handler) {
+	s.mux.Handle(pattern, s.wrap(s.client(s.clientGuard(Wrap(h)))))
+}
+
+// ServeHTTP implements http.Handler.
+// The requests outside of the base path of s are answered with 404.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	base := s.config.base
+	switch {
+	case base == "":
+		s.mux.ServeHTTP(w, r)
+	case r.URL.Path == base:
+		http.Redirect(w, r, base+"/", http.StatusMovedPermanently)
+	case strings.HasPrefix(r.URL.Path, base+"/"):
+		http.StripPrefix(base, http.HandlerFunc(s.serveUnder)).ServeHTTP(w, r)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// serveUnder serve r, stripped from the base path. The redirects of the
+// http.ServeMux, to the clean path or to the folder of a path missing its
+// trailing slash, are done here to keep the base path.
+func (s *Server) serveUnder(w http.ResponseWriter, r *http.Request) {
+	target := path.Clean(r.URL.Path)
+	if strings.HasSuffix(r.URL.Path, "/") && target != "/" {
+		target += "/"
+	}
+	if _, pattern := s.mux.Handler(r); target == r.URL.Path && pattern == target+"/" {
+		target += "/"
+	}
+	if target == r.URL.Path {
+		s.mux.ServeHTTP(w, r)
+		return
+	}
+	if r.URL.RawQuery != "" {
+		target += "?" + r.URL.RawQuery
+	}
+	http.Redirect(w, r, s.config.base+target, http.StatusTemporaryRedirect)
+}
+
+// buildSearch start building the search index in background, unless it
+// was already started. Shutdown stops the build.
+func (s *Server) buildSearch() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.built != nil {
+		return
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	built := make(chan struct{})
+	s.cancel, s.built = cancel, built
+	go func() {
+		defer close(built)
+		if err := s.search.Build(ctx); err != nil && ctx.Err() == nil {
+			fmt.Fprintln(os.Stderr, "building search index:", err)
+		}
+	}()
+}
+
+// serve build the search index in background and serve the wiki with srv,
+// using listen to accept the connections.
+func (s *Server) serve(srv *http.Server, listen func() error) error {
+	s.mu.Lock()
+	s.servers = append(s.servers, srv)
+	s.mu.Unlock()
+	s.buildSearch()
+	return listen()
+}
+
+// ListenAndServe build the search index in background and serve the wiki
+// on the address of s. It return http.ErrServerClosed after Shutdown.
+func (s *Server) ListenAndServe() error {
+	srv := &http.Server{Addr: s.config.addr, Handler: s}
+	fmt.Println("Listening on " + s.config.addr)
+	return s.serve(srv, srv.ListenAndServe)
+}
+
+// Serve is like ListenAndServe, accepting the connections of l.
+func (s *Server) Serve(l net.Listener) error {
+	srv := &http.Server{Handler: s}
+	return s.serve(srv, func() error {
+		return srv.Serve(l)
+	})
+}
+
+// ListenAndServeUnix is like ListenAndServe, on the unix socket path
+// created with the permissions mode. A socket left at path by a previous
+// run is replaced, and the socket is removed by Shutdown.
+func (s *Server) ListenAndServeUnix(path string, mode os.FileMode) error {
+	if fi, err := os.Lstat(path); err == nil && fi.Mode()&os.ModeSocket != 0 {
+		if err := os.Remove(path); err != nil {
+			return err
+		}
+	}
+	l, err := net.Listen("unix", path)
+	if err != nil {
+		return err
+	}
+	if err := os.Chmod(path, mode); err != nil {
+		l.Close()
+		return err
+	}
+	fmt.Println("Listening on unix socket " + path)
+	return s.Serve(l)
+}
+
+// redirectHTTPS redirect the request to the same URL in HTTPS.
+func redirectHTTPS(w http.ResponseWriter, r *http.Request) {
+	host, _, err := net.SplitHostPort(r.Host)
+	if err != nil {
+		host = r.Host
+	}
+	http.Redirect(w, r, "https://"+host+r.URL.RequestURI(), http.StatusMovedPermanently)
+}
+
+// ListenAndServeTLS is like ListenAndServe, over TLS with the certificate
+// and key files certFile and keyFile. They are left empty with
+// ServerAutocert, the certificates coming from Let's Encrypt. The address
+// of ServerRedirectHTTP redirects to HTTPS.
+func (s *Server) ListenAndServeTLS(certFile, keyFile string) error {
+	c := &s.config
+	srv := &http.Server{Addr: c.addr, Handler: s}
+	var redirect http.Handler = http.HandlerFunc(redirectHTTPS)
+	if c.autocert != nil {
+		srv.TLSConfig = c.autocert.TLSConfig()
+		redirect = c.autocert.HTTPHandler(redirect)
+	}
+	if c.redirectAddr != "" {
+		rsrv := &http.Server{Addr: c.redirectAddr, Handler: redirect}
+		s.mu.Lock()
+		s.servers = append(s.servers, rsrv)
+		s.mu.Unlock()
+		go func() {
+			fmt.Println("Redirecting to HTTPS on " + c.redirectAddr)
+			if err := rsrv.ListenAndServe(); err != http.ErrServerClosed {
+				fmt.Fprintln(os.Stderr, err)
+			}
+		}()
+	}
+	fmt.Println("Listening on " + c.addr + " with TLS")
+	return s.serve(srv, func() error {
+		return srv.ListenAndServeTLS(certFile, keyFile)
+	})
+}
+
+// Shutdown stop s gracefully: it stops accepting connections and waits for
+// the requests in flight, as http.Server.Shutdown, then stops the build of
+// the search index, writes the pending autosaves and closes the store when
+// it implements io.Closer. It return the first error, ctx.Err() when ctx
+// ends before the requests.
+func (s *Server) Shutdown(ctx context.Context) error {
+	s.mu.Lock()
+	servers, cancel, built := s.servers, s.cancel, s.built
+	s.mu.Unlock()
+
+	var first error
+	keep := func(err error) {
+		if err != nil && first == nil {
+			first = err
+		}
+	}
+	for _, srv := range servers {
+		keep(srv.Shutdown(ctx))
+	}
+	if cancel != nil {
+		cancel()
+		select {
+		case <-built:
+		case <-ctx.Done():
+		}
+	}
+	if s.saves != nil {
+		keep(s.saves.Flush())
+	}
+	if c, ok := s.store.(io.Closer); ok {
+		keep(c.Close())
+	}
+	return first
+}
@@ -0,0 +1,198 @@
+package mngr
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// UserStore authenticate the users of the wiki.
+type UserStore interface {
+	// Check report whether password is the password of user.
+	Check(user, password string) bool
+}
+
+var _ UserStore = (*Htpasswd)(nil)
+
+// sessionCookie is the name of the cookie holding the session.
+const sessionCookie = "mngr_session"
+
+// Sessions remember the logged in users with a signed cookie. The cookie
+// holds the name of the user and the expiration of the session, it can't be
+// altered without the key.
+type Sessions struct {
+	users  UserStore
+	key    []byte
+	maxAge time.Duration
+}
+
+// NewSessions create Sessions authenticating users, whose cookies are
+// signed with key and expire after maxAge. users may be nil when the users
+// log in with an AuthProvider instead of MakeLoginHandler.
+func NewSessions(users UserStore, key []byte, maxAge time.Duration) *Sessions {
+	return &Sessions{users: users, key: key, maxAge: maxAge}
+}
+
+// sign return the MAC of payload.
+func (s *Sessions) sign(payload string) []byte {
+	mac := hmac.New(sha256.New, s.key)
+	mac.Write([]byte(payload))
+	return mac.Sum(nil)
+}
+
+// Login start a session for user.
+func (s *Sessions) Login(w http.ResponseWriter, r *http.Request, user string) {
+	expires := time.Now().Add(s.maxAge)
+	payload := user + "|" + strconv.FormatInt(expires.Unix(), 10)
+	value := base64.RawURLEncoding.EncodeToString([]byte(payload)) + "." +
+		base64.RawURLEncoding.EncodeToString(s.sign(payload))
+	http.SetCookie(w, &http.Cookie{
+		Name:     sessionCookie,
+		Value:    value,
+		Path:     "/",
+		Expires:  expires,
+		MaxAge:   int(s.maxAge / time.Second),
+		Secure:   r.TLS != nil,
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+// Logout end the session of r.
+func (s *Sessions) Logout(w http.ResponseWriter, r *http.Request) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     sessionCookie,
+		Path:     "/",
+		MaxAge:   -1,
+		Secure:   r.TLS != nil,
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+// User return the user logged in by the session of r.
+func (s *Sessions) User(r *http.Request) (string, bool) {
+	c, err := r.Cookie(sessionCookie)
+	if err != nil {
+		return "", false
+	}
+	i := strings.IndexByte(c.Value, '.')
+	if i < 0 {
+		return "", false
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(c.Value[:i])
+	if err != nil {
+		return "", false
+	}
+	mac, err := base64.RawURLEncoding.DecodeString(c.Value[i+1:])
+	if err != nil || !hmac.Equal(mac, s.sign(string(payload))) {
+		return "", false
+	}
+	j := strings.LastIndexByte(string(payload), '|')
+	if j < 0 {
+		return "", false
+	}
+	expires, err := strconv.ParseInt(string(payload[j+1:]), 10, 64)
+	if err != nil || time.Now().Unix() >= expires {
+		return "", false
+	}
+	return string(payload[:j]), true
+}
+
+// MakeSessionMiddleware create a middleware adding the user logged in by
+// the session of the request to its context, see UserFromCtx. Requests
+// without a valid session are handed over unchanged.
+func MakeSessionMiddleware(s *Sessions) Middleware {
+	return func(h Handler) Handler {
+		return HandlerFunc(func(w http.ResponseWriter, r *http.Request) (int, error) {
+			if user, ok := s.User(r); ok {
+				r = r.WithContext(context.WithValue(r.Context(), userKey, user))
+			}
+			return h.ServeHTTP(w, r)
+		})
+	}
+}
+
+// MakeRequireUserMiddleware create a middleware rejecting the requests
+// without user in their context. GET requests are redirected to the login
+// URL, with the URL requested as 'next' value, others are answered with 401.
+func MakeRequireUserMiddleware(login string) Middleware {
+	return func(h Handler) Handler {
+		return HandlerFunc(func(w http.ResponseWriter, r *http.Request) (int, error) {
+			if _, ok := UserFromCtx(r.Context()); ok {
+				return h.ServeHTTP(w, r)
+			}
+			if r.Method == http.MethodGet || r.Method == http.MethodHead {
+				redirect(w, r, login+"?next="+url.QueryEscape(r.URL.RequestURI()), http.StatusFound)
+				return http.StatusFound, nil
+			}
+			w.Header().Set("Content-Type", "text/plain")
+			w.WriteHeader(http.StatusUnauthorized)
+			w.Write([]byte("unauthorized"))
+			return http.StatusUnauthorized, nil
+		})
+	}
+}
+
+// localURL report whether u is a path of this server, safe to redirect to.
+func localURL(u string) bool {
+	return strings.HasPrefix(u, "/") && !strings.HasPrefix(u, "//") && !strings.HasPrefix(u, "/\\")
+}
+
+// MakeLoginHandler return an handler logging in the users of s, using
+// login.html. POST requests check the 'user' and 'password' values and
+// redirect to the local URL given by the 'next' value when they match.
+func MakeLoginHandler(s *Sessions) HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) (int, error) {
+		next := r.FormValue("next")
+		if !localURL(next) {
+			next = "/"
+		}
+		code := http.StatusOK
+		if r.Method == http.MethodPost {
+			user := r.PostFormValue("user")
+			if s.users.Check(user, r.PostFormValue("password")) {
+				s.Login(w, r, user)
+				redirect(w, r, next, http.StatusFound)
+				return http.StatusFound, nil
+			}
+			code = http.StatusUnauthorized
+		}
+		v := &struct {
+			TemplateInfo
+			Next   string
+			Failed bool
+		}{
+			TemplateInfo: newTemplateInfo(r, ValidURL{Action: "login"}),
+			Next:         next,
+			Failed:       code != http.StatusOK,
+		}
+		t, _ := TemplateFromCtx(r.Context())
+		w.WriteHeader(code)
+		err := t.ExecuteTemplate(w, "login.html", v)
+		return code, err
+	}
+}
+
+// MakeLogoutHandler return an handler ending the session of POST requests
+// and redirecting to the home page.
+func MakeLogoutHandler(s *Sessions) HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) (int, error) {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			w.Header().Set("Content-Type", "text/plain")
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			w.Write([]byte("method not allowed"))
+			return http.StatusMethodNotAllowed, nil
+		}
+		s.Logout(w, r)
+		redirect(w, r, "/", http.StatusFound)
+		return http.StatusFound, nil
+	}
+}
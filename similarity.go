@@ -0,0 +1,49 @@
+package mngr
+
+import (
+	"net/http"
+)
+
+// MakeSimilarityHandler return an handler comparing the pages given by the
+// 'a' and 'b' query values. The JSON response contains the Jaccard index of
+// their word sets, from 0 for nothing in common to 1 for the same words.
+// The ACL of the request, if any, must let the user read both pages.
+func MakeSimilarityHandler(s Store) HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) (int, error) {
+		a := r.URL.Query().Get("a")
+		b := r.URL.Query().Get("b")
+		if !validPagePath.MatchString(a) || !validPagePath.MatchString(b) {
+			w.Header().Set("Content-Type", "text/plain")
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte("bad request: invalid page path"))
+			return http.StatusBadRequest, nil
+		}
+		if readable := readFilter(r.Context()); readable != nil && (!readable(a) || !readable(b)) {
+			w.Header().Set("Content-Type", "text/plain")
+			w.WriteHeader(http.StatusForbidden)
+			w.Write([]byte("forbidden: viewer role required on both pages"))
+			return http.StatusForbidden, nil
+		}
+		bodyA, err := s.Read(a)
+		if err != nil {
+			return 0, err
+		}
+		bodyB, err := s.Read(b)
+		if err != nil {
+			return 0, err
+		}
+		return writeJSON(w, http.StatusOK, struct {
+			A           string  `json:"a"`
+			B           string  `json:"b"`
+			Method      string  `json:"method"`
+			Description string  `json:"description"`
+			Score       float64 `json:"score"`
+		}{
+			A:           a,
+			B:           b,
+			Method:      "jaccard",
+			Description: "distinct lower case words found in both pages divided by distinct words found in either, front matter excluded",
+			Score:       jaccard(tokenize(bodyA), tokenize(bodyB)),
+		})
+	}
+}
@@ -0,0 +1,89 @@
+package mngr
+
+import (
+	"context"
+	"net/http"
+)
+
+// SiteNode represent an entry of the site index. Folders have children,
+// files don't.
+type SiteNode struct {
+	Name     string
+	Path     string
+	IsDir    bool
+	Children []SiteNode
+}
+
+// siteTree is the data of the site-tree template: the nodes of a level and
+// the base path of the links.
+type siteTree struct {
+	Base  string
+	Nodes []SiteNode
+}
+
+// Sub return the tree of the children nodes.
+func (t siteTree) Sub(nodes []SiteNode) siteTree {
+	return siteTree{Base: t.Base, Nodes: nodes}
+}
+
+// buildSiteTree walks dir in s and return its content as a tree.
+// Folders come first, then files, both sorted alphabetically. The walk
+// stops at maxDepth, a maxDepth of 0 or less means no limit. The entries
+// for which readable, when not nil, return false are skipped.
+func buildSiteTree(ctx context.Context, s Store, dir string, readable func(p string) bool, depth, maxDepth int) ([]SiteNode, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	fInfos, err := s.List(dir)
+	if err != nil {
+		return nil, err
+	}
+	files, folders := filterFiles(fInfos)
+	nodes := make([]SiteNode, 0, len(files)+len(folders))
+	for _, name := range folders {
+		if readable != nil && !readable(dir+name) {
+			continue
+		}
+		n := SiteNode{Name: name, Path: dir + name + "/", IsDir: true}
+		if maxDepth <= 0 || depth < maxDepth {
+			n.Children, err = buildSiteTree(ctx, s, n.Path, readable, depth+1, maxDepth)
+			if err != nil {
+				return nil, err
+			}
+		}
+		nodes = append(nodes, n)
+	}
+	for _, name := range files {
+		if readable != nil && !readable(dir+name) {
+			continue
+		}
+		nodes = append(nodes, SiteNode{Name: name, Path: dir + name})
+	}
+	return nodes, nil
+}
+
+// MakeSiteIndexHandler return an handler which render a human readable index
+// of every page located under the requested folder, using index.html.
+// Hidden files and folders are skipped, like those the ACL of the request,
+// if any, doesn't let the user read, and the walk doesn't go deeper than
+// maxDepth folders, 0 meaning no limit.
+func MakeSiteIndexHandler(s Store, maxDepth int) ContextHandlerFunc {
+	return func(ctx context.Context, w http.ResponseWriter, r *http.Request) (int, error) {
+		valid, _ := ValidURLFromCtx(ctx)
+		nodes, err := buildSiteTree(ctx, s, valid.Dir, readFilter(ctx), 1, maxDepth)
+		if err != nil {
+			return 0, err
+		}
+		v := &struct {
+			TemplateInfo
+			Tree siteTree
+		}{
+			TemplateInfo: newTemplateInfo(r, valid),
+		}
+		v.Tree = siteTree{Base: v.Base, Nodes: nodes}
+
+		t, _ := TemplateFromCtx(ctx)
+		err = t.ExecuteTemplate(w, "index.html", v)
+		return 200, err
+	}
+}
@@ -0,0 +1,193 @@
+package mngr
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"os"
+	"time"
+)
+
+// snapshotsDir is the hidden folder, at the root of the store, storing
+// snapshots.
+const snapshotsDir = ".snapshots"
+
+// ManifestEntry describe the state of a file.
+type ManifestEntry struct {
+	Path    string    `json:"path"`
+	Hash    string    `json:"hash"`
+	Size    int64     `json:"size"`
+	ModTime time.Time `json:"modTime"`
+}
+
+// Snapshot is the manifest of a folder recorded at a given time.
+type Snapshot struct {
+	Name    string          `json:"name"`
+	Dir     string          `json:"dir"`
+	Created time.Time       `json:"created"`
+	Files   []ManifestEntry `json:"files"`
+}
+
+// buildManifest describe every file located under dir in store which the
+// user of ctx can read.
+func buildManifest(ctx context.Context, store Store, dir string) ([]ManifestEntry, error) {
+	files := []ManifestEntry{}
+	err := walkFiles(ctx, store, dir, readFilter(ctx), func(path string) error {
+		fi, err := store.Stat(path)
+		if err != nil {
+			return err
+		}
+		hash, err := hashFile(store, path)
+		if err != nil {
+			return err
+		}
+		files = append(files, ManifestEntry{Path: path, Hash: hash, Size: fi.Size(), ModTime: fi.ModTime()})
+		return nil
+	})
+	return files, err
+}
+
+// snapshotPath return the file storing the snapshot name.
+func snapshotPath(name string) string {
+	return snapshotsDir + "/" + name + ".json"
+}
+
+func loadSnapshot(store Store, name string) (*Snapshot, error) {
+	body, err := store.Read(snapshotPath(name))
+	if err != nil {
+		return nil, err
+	}
+	s := &Snapshot{}
+	return s, json.Unmarshal(body, s)
+}
+
+// readableFiles return the entries of files which readable accepts, all of
+// them when readable is nil.
+func readableFiles(files []ManifestEntry, readable func(p string) bool) []ManifestEntry {
+	if readable == nil {
+		return files
+	}
+	kept := []ManifestEntry{}
+	for _, e := range files {
+		if readable(e.Path) {
+			kept = append(kept, e)
+		}
+	}
+	return kept
+}
+
+func saveSnapshot(store Store, s *Snapshot) error {
+	err := store.Mkdir(snapshotsDir)
+	if err != nil && !os.IsExist(err) {
+		return err
+	}
+	name := snapshotPath(s.Name)
+	if _, err := store.Stat(name); err == nil {
+		return &os.PathError{Op: "snapshot", Path: name, Err: os.ErrExist}
+	}
+	body, err := json.Marshal(s)
+	if err != nil {
+		return err
+	}
+	return store.Write(name, body)
+}
+
+// writeBadName answer a request carrying an invalid snapshot name.
+func writeBadName(w http.ResponseWriter) (int, error) {
+	w.Header().Set("Content-Type", "text/plain")
+	w.WriteHeader(http.StatusBadRequest)
+	w.Write([]byte("bad request: invalid snapshot name"))
+	return http.StatusBadRequest, nil
+}
+
+// MakeSnapshotHandler return an handler recording the manifest of the
+// requested folder under the snapshot given by the 'name' value. Only POST
+// requests record a snapshot, others get the manifest without saving it.
+// Existing snapshots are never overwritten.
+func MakeSnapshotHandler(store Store) ContextHandlerFunc {
+	return func(ctx context.Context, w http.ResponseWriter, r *http.Request) (int, error) {
+		valid, _ := ValidURLFromCtx(ctx)
+		name := r.FormValue("name")
+		if r.Method == http.MethodPost && !validName.MatchString(name) {
+			return writeBadName(w)
+		}
+		files, err := buildManifest(ctx, store, valid.Dir)
+		if err != nil {
+			return 0, err
+		}
+		s := &Snapshot{Name: name, Dir: valid.Dir, Created: time.Now(), Files: files}
+		if r.Method == http.MethodPost {
+			if err := saveSnapshot(store, s); err != nil {
+				return 0, err
+			}
+			return writeJSON(w, http.StatusCreated, s)
+		}
+		return writeJSON(w, http.StatusOK, s)
+	}
+}
+
+// SnapshotDiff contains the changes between two snapshots.
+type SnapshotDiff struct {
+	From     string          `json:"from"`
+	To       string          `json:"to"`
+	Added    []ManifestEntry `json:"added"`
+	Removed  []ManifestEntry `json:"removed"`
+	Modified []ManifestEntry `json:"modified"`
+}
+
+// diffSnapshots compare two snapshots, a file is modified when its content
+// changed. Modified entries describe the file as found in to.
+func diffSnapshots(from, to *Snapshot) *SnapshotDiff {
+	d := &SnapshotDiff{
+		From:     from.Name,
+		To:       to.Name,
+		Added:    []ManifestEntry{},
+		Removed:  []ManifestEntry{},
+		Modified: []ManifestEntry{},
+	}
+	before := make(map[string]ManifestEntry, len(from.Files))
+	for _, e := range from.Files {
+		before[e.Path] = e
+	}
+	after := make(map[string]bool, len(to.Files))
+	for _, e := range to.Files {
+		after[e.Path] = true
+		old, ok := before[e.Path]
+		switch {
+		case !ok:
+			d.Added = append(d.Added, e)
+		case old.Hash != e.Hash:
+			d.Modified = append(d.Modified, e)
+		}
+	}
+	for _, e := range from.Files {
+		if !after[e.Path] {
+			d.Removed = append(d.Removed, e)
+		}
+	}
+	return d
+}
+
+// MakeSnapshotDiffHandler return an handler comparing the snapshots given
+// by the 'from' and 'to' query values, listing the added, removed and
+// modified files as JSON. The files the user can't read are left out.
+func MakeSnapshotDiffHandler(store Store) HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) (int, error) {
+		from := r.URL.Query().Get("from")
+		to := r.URL.Query().Get("to")
+		if !validName.MatchString(from) || !validName.MatchString(to) {
+			return writeBadName(w)
+		}
+		a, err := loadSnapshot(store, from)
+		if err != nil {
+			return 0, err
+		}
+		b, err := loadSnapshot(store, to)
+		if err != nil {
+			return 0, err
+		}
+		readable := readFilter(r.Context())
+		a.Files, b.Files = readableFiles(a.Files, readable), readableFiles(b.Files, readable)
+		return writeJSON(w, http.StatusOK, diffSnapshots(a, b))
+	}
+}
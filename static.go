@@ -0,0 +1,127 @@
+package mngr
+
+import (
+	"io"
+	"io/fs"
+	"mime"
+	"net/http"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// statusWriter is an http.ResponseWriter which
+// captures the status set with WriteHeader.
+type statusWriter struct {
+	http.ResponseWriter
+	status int
+}
+
+// WriteHeader is a redefinition of http.ResponseWriter.WriteHeader.
+// This function allows us to capture the status set by an handler.
+func (w *statusWriter) WriteHeader(status int) {
+	w.status = status
+	w.ResponseWriter.WriteHeader(status)
+}
+
+// Write is a redefinition of http.ResponseWriter.Write.
+// It records the implicit 200 status of responses without WriteHeader.
+func (w *statusWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+// Unwrap return the wrapped http.ResponseWriter, for http.ResponseController.
+func (w *statusWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// precompressed lists the sidecar files served in place of a file,
+// by order of preference.
+var precompressed = []struct {
+	encoding, ext string
+}{
+	{"br", ".br"},
+	{"gzip", ".gz"},
+}
+
+// acceptsEncoding report whether the request's Accept-Encoding allows
+// the given content encoding.
+func acceptsEncoding(r *http.Request, encoding string) bool {
+	accepted := false
+	for _, part := range strings.Split(r.Header.Get("Accept-Encoding"), ",") {
+		fields := strings.Split(part, ";")
+		name := strings.TrimSpace(fields[0])
+		if name != encoding && name != "*" {
+			continue
+		}
+		q := 1.0
+		for _, param := range fields[1:] {
+			param = strings.TrimSpace(param)
+			if strings.HasPrefix(param, "q=") {
+				q, _ = strconv.ParseFloat(param[2:], 64)
+			}
+		}
+		if name == encoding {
+			return q > 0
+		}
+		accepted = q > 0
+	}
+	return accepted
+}
+
+// servePrecompressed serve the .br or .gz sidecar of name in fsys when the
+// client accept its encoding. It return false when no sidecar was served.
+func servePrecompressed(w http.ResponseWriter, r *http.Request, fsys fs.FS, name string) bool {
+	name = path.Clean("/" + name)
+	w.Header().Add("Vary", "Accept-Encoding")
+	for _, p := range precompressed {
+		if !acceptsEncoding(r, p.encoding) {
+			continue
+		}
+		f, err := fsys.Open(strings.TrimPrefix(name, "/") + p.ext)
+		if err != nil {
+			continue
+		}
+		defer f.Close()
+		fi, err := f.Stat()
+		content, ok := f.(io.ReadSeeker)
+		if err != nil || fi.IsDir() || !ok {
+			continue
+		}
+		ctype := mime.TypeByExtension(path.Ext(name))
+		if ctype == "" {
+			ctype = "application/octet-stream"
+		}
+		w.Header().Set("Content-Type", ctype)
+		w.Header().Set("Content-Encoding", p.encoding)
+		http.ServeContent(w, r, name, fi.ModTime(), content)
+		return true
+	}
+	return false
+}
+
+// MakeStaticHandler return an handler serving the files of dir under the
+// URL prefix. When the client accept it, a precompressed '.br' or '.gz'
+// sidecar is served in place of the requested file.
+func MakeStaticHandler(dir, prefix string) HandlerFunc {
+	return MakeFSStaticHandler(os.DirFS(dir), prefix)
+}
+
+// MakeFSStaticHandler works like MakeStaticHandler with the files of fsys,
+// like DefaultStatic.
+func MakeFSStaticHandler(fsys fs.FS, prefix string) HandlerFunc {
+	fileServer := http.StripPrefix(prefix, http.FileServer(http.FS(fsys)))
+	return func(w http.ResponseWriter, r *http.Request) (int, error) {
+		sw := &statusWriter{ResponseWriter: w}
+		name := strings.TrimPrefix(r.URL.Path, prefix)
+		if !strings.HasSuffix(name, "/") && servePrecompressed(sw, r, fsys, name) {
+			return sw.status, nil
+		}
+		fileServer.ServeHTTP(sw, r)
+		return sw.status, nil
+	}
+}
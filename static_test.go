@@ -0,0 +1,86 @@
+package mngr
+
+import (
+	"net/http/httptest"
+	"testing"
+	"testing/fstest"
+)
+
+func TestServePrecompressed(t *testing.T) {
+	fsys := fstest.MapFS{
+		"app.css":    {Data: []byte("plain css")},
+		"app.css.br": {Data: []byte("br css")},
+		"app.css.gz": {Data: []byte("gzip css")},
+		"app.js":     {Data: []byte("plain js")},
+		"app.js.gz":  {Data: []byte("gzip js")},
+		"logo.svg":   {Data: []byte("plain svg")},
+	}
+	tests := []struct {
+		name, file, accept string
+		// encoding is the served sidecar, empty when none is.
+		encoding, body string
+	}{
+		{"brotli preferred", "app.css", "gzip, deflate, br", "br", "br css"},
+		{"gzip only", "app.css", "gzip", "gzip", "gzip css"},
+		{"brotli refused", "app.css", "br;q=0, gzip;q=0.8", "gzip", "gzip css"},
+		{"wildcard", "app.css", "br;q=0, *", "gzip", "gzip css"},
+		{"everything refused", "app.css", "br;q=0, gzip;q=0", "", ""},
+		{"no accept encoding", "app.css", "", "", ""},
+		{"missing brotli sidecar", "app.js", "br, gzip", "gzip", "gzip js"},
+		{"missing sidecars", "logo.svg", "br, gzip", "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest("GET", "/static/"+tt.file, nil)
+			if tt.accept != "" {
+				r.Header.Set("Accept-Encoding", tt.accept)
+			}
+			served := servePrecompressed(w, r, fsys, tt.file)
+			if served != (tt.encoding != "") {
+				t.Fatalf("served = %v, want sidecar %q", served, tt.encoding)
+			}
+			if got := w.Header().Get("Vary"); got != "Accept-Encoding" {
+				t.Errorf("Vary = %q", got)
+			}
+			if !served {
+				return
+			}
+			if got := w.Header().Get("Content-Encoding"); got != tt.encoding {
+				t.Errorf("Content-Encoding = %q, want %q", got, tt.encoding)
+			}
+			if got := w.Body.String(); got != tt.body {
+				t.Errorf("body = %q, want %q", got, tt.body)
+			}
+		})
+	}
+}
+
+func TestStaticHandlerFallback(t *testing.T) {
+	fsys := fstest.MapFS{
+		"logo.svg":  {Data: []byte("plain svg")},
+		"app.js":    {Data: []byte("plain js")},
+		"app.js.gz": {Data: []byte("gzip js")},
+	}
+	h := MakeFSStaticHandler(fsys, "/static/")
+	tests := []struct{ file, accept, body string }{
+		{"logo.svg", "br, gzip", "plain svg"},
+		{"app.js", "br", "plain js"},
+		{"app.js", "identity", "plain js"},
+	}
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest("GET", "/static/"+tt.file, nil)
+		r.Header.Set("Accept-Encoding", tt.accept)
+		code, err := h.ServeHTTP(w, r)
+		if err != nil || code != 200 {
+			t.Fatalf("%s with %q: %d %v", tt.file, tt.accept, code, err)
+		}
+		if got := w.Header().Get("Content-Encoding"); got != "" {
+			t.Errorf("%s with %q: Content-Encoding = %q", tt.file, tt.accept, got)
+		}
+		if got := w.Body.String(); got != tt.body {
+			t.Errorf("%s with %q: body = %q, want %q", tt.file, tt.accept, got, tt.body)
+		}
+	}
+}
@@ -0,0 +1,312 @@
+package mngr
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"time"
+)
+
+type storeCtxKey int
+
+var storeKey = storeCtxKey(0)
+
+// Store persist the files and folders of the wiki. Names are slash
+// separated paths relative to the root of the store. Errors must be
+// recognized by os.IsNotExist, os.IsExist and os.IsPermission so they are
+// reported with the right status by MakeErrorMiddleware.
+type Store interface {
+	// Read return the content of the file name.
+	Read(name string) ([]byte, error)
+	// Write replace the content of the file name, creating it if needed.
+	Write(name string, body []byte) error
+	// List return the content of the folder name, sorted by name.
+	List(name string) ([]os.FileInfo, error)
+	// Mkdir create the folder name, its parent must exist.
+	Mkdir(name string) error
+	// Remove delete the file or the folder name, with everything it contains.
+	Remove(name string) error
+	// Stat describe the file or the folder name.
+	Stat(name string) (os.FileInfo, error)
+}
+
+// Renamer is implemented by stores able to move a file or a folder
+// without copying it. Other stores are copied then removed.
+type Renamer interface {
+	Rename(from, to string) error
+}
+
+// Toucher is implemented by stores able to change the modification time
+// of a file.
+type Toucher interface {
+	Touch(name string, t time.Time) error
+}
+
+// Opener is implemented by stores able to stream the content of a file
+// instead of reading it whole, see openFile.
+type Opener interface {
+	Open(name string) (io.ReadSeekCloser, error)
+}
+
+// Creator is implemented by stores able to write a file while it is
+// streamed instead of buffering it whole, see createFile. When excl is set,
+// Create fails with an error recognized by os.IsExist if the file already
+// exists. The file is complete once closed; writers implementing Abort()
+// drop it instead, others are closed and removed, see discardFile.
+type Creator interface {
+	Create(name string, excl bool) (io.WriteCloser, error)
+}
+
+// openFile open the file name of s with Open when s is an Opener, other
+// stores Read it whole.
+func openFile(s Store, name string) (io.ReadSeekCloser, error) {
+	if o, ok := s.(Opener); ok {
+		return o.Open(name)
+	}
+	body, err := s.Read(name)
+	if err != nil {
+		return nil, err
+	}
+	return readerNopCloser{bytes.NewReader(body)}, nil
+}
+
+// readerNopCloser is the file of openFile for the stores which aren't
+// Openers.
+type readerNopCloser struct {
+	*bytes.Reader
+}
+
+func (readerNopCloser) Close() error {
+	return nil
+}
+
+// createFile create the file name of s with Create when s is a Creator.
+// Other stores buffer the content and Write it on Close, the check of excl
+// isn't atomic for them.
+func createFile(s Store, name string, excl bool) (io.WriteCloser, error) {
+	if c, ok := s.(Creator); ok {
+		return c.Create(name, excl)
+	}
+	if _, err := s.Stat(name); excl && err == nil {
+		return nil, &os.PathError{Op: "create", Path: name, Err: os.ErrExist}
+	}
+	return &bufferedFile{store: s, name: name}, nil
+}
+
+// bufferedFile is the file of createFile for the stores which aren't
+// Creators.
+type bufferedFile struct {
+	bytes.Buffer
+	store Store
+	name  string
+}
+
+// Close write the content of f to its store.
+func (f *bufferedFile) Close() error {
+	return f.store.Write(f.name, f.Bytes())
+}
+
+// Abort drop the content of f, nothing is written.
+func (f *bufferedFile) Abort() {
+	f.Reset()
+}
+
+// discardFile drop the file name of s, created by createFile and written
+// with f, whose content was refused.
+func discardFile(s Store, name string, f io.WriteCloser) {
+	if a, ok := f.(interface{ Abort() }); ok {
+		a.Abort()
+		return
+	}
+	f.Close()
+	s.Remove(name)
+}
+
+// ContextStore is implemented by stores whose operations can be canceled,
+// like the network stores: WithContext return the Store doing its
+// operations with ctx, which stop when ctx is done.
+type ContextStore interface {
+	WithContext(ctx context.Context) Store
+}
+
+// StoreWithContext return s doing its operations with ctx, when it is a
+// ContextStore, or s unchanged.
+func StoreWithContext(ctx context.Context, s Store) Store {
+	if cs, ok := s.(ContextStore); ok {
+		return cs.WithContext(ctx)
+	}
+	return s
+}
+
+// DirStore is a Store backed by a folder of the local filesystem.
+// Every operation but List and Stat is retried following FSRetry.
+type DirStore string
+
+// path return the filesystem path of name, which can't leave d.
+func (d DirStore) path(name string) string {
+	return filepath.Join(string(d), filepath.FromSlash(path.Clean("/"+name)))
+}
+
+// Read implements Store.
+func (d DirStore) Read(name string) ([]byte, error) {
+	var body []byte
+	err := FSRetry.do("read "+name, func() error {
+		var err error
+		body, err = ioutil.ReadFile(d.path(name))
+		return err
+	})
+	return body, err
+}
+
+// Write implements Store.
+func (d DirStore) Write(name string, body []byte) error {
+	return FSRetry.do("write "+name, func() error {
+		return ioutil.WriteFile(d.path(name), body, 0600)
+	})
+}
+
+// List implements Store.
+func (d DirStore) List(name string) ([]os.FileInfo, error) {
+	return ioutil.ReadDir(d.path(name))
+}
+
+// Open implements Opener.
+func (d DirStore) Open(name string) (io.ReadSeekCloser, error) {
+	var f *os.File
+	err := FSRetry.do("open "+name, func() error {
+		var err error
+		f, err = os.Open(d.path(name))
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// Create implements Creator.
+func (d DirStore) Create(name string, excl bool) (io.WriteCloser, error) {
+	flag := os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+	if excl {
+		flag = os.O_WRONLY | os.O_CREATE | os.O_EXCL
+	}
+	var f *os.File
+	err := FSRetry.do("create "+name, func() error {
+		var err error
+		f, err = os.OpenFile(d.path(name), flag, 0600)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// Mkdir implements Store.
+func (d DirStore) Mkdir(name string) error {
+	return FSRetry.do("mkdir "+name, func() error {
+		return os.Mkdir(d.path(name), 0700)
+	})
+}
+
+// Remove implements Store.
+func (d DirStore) Remove(name string) error {
+	return FSRetry.do("remove "+name, func() error {
+		return os.RemoveAll(d.path(name))
+	})
+}
+
+// Stat implements Store.
+func (d DirStore) Stat(name string) (os.FileInfo, error) {
+	return os.Stat(d.path(name))
+}
+
+// Rename implements Renamer.
+func (d DirStore) Rename(from, to string) error {
+	return FSRetry.do("rename "+from, func() error {
+		return os.Rename(d.path(from), d.path(to))
+	})
+}
+
+// Touch implements Toucher.
+func (d DirStore) Touch(name string, t time.Time) error {
+	return FSRetry.do("touch "+name, func() error {
+		return os.Chtimes(d.path(name), t, t)
+	})
+}
+
+// copyTree recursively copy the file or folder src of s to dst.
+func copyTree(s Store, src, dst string) error {
+	fi, err := s.Stat(src)
+	if err != nil {
+		return err
+	}
+	if !fi.IsDir() {
+		body, err := s.Read(src)
+		if err != nil {
+			return err
+		}
+		return s.Write(dst, body)
+	}
+	// The content is listed first, so a dst inside src isn't copied.
+	fInfos, err := s.List(src)
+	if err != nil {
+		return err
+	}
+	if err := s.Mkdir(dst); err != nil {
+		return err
+	}
+	for _, f := range fInfos {
+		if err := copyTree(s, src+"/"+f.Name(), dst+"/"+f.Name()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// rename move from to to, using s.Rename when s is a Renamer.
+func rename(s Store, from, to string) error {
+	if r, ok := s.(Renamer); ok {
+		return r.Rename(from, to)
+	}
+	if err := copyTree(s, from, to); err != nil {
+		return err
+	}
+	return s.Remove(from)
+}
+
+// NewContextWithStore return a copy of ctx holding s, like
+// MakeStoreMiddleware does, for the handlers to read with StoreFromCtx.
+func NewContextWithStore(ctx context.Context, s Store) context.Context {
+	return context.WithValue(ctx, storeKey, s)
+}
+
+// StoreFromCtx extract a Store added by MakeStoreMiddleware from a context.
+// The Store does its operations with the context, see StoreWithContext.
+// When the context holds a Tracer, the Store is traced, see TraceStore.
+func StoreFromCtx(ctx context.Context) (Store, bool) {
+	s, ok := ctx.Value(storeKey).(Store)
+	if ok {
+		s = StoreWithContext(ctx, s)
+	}
+	if ok && traced(ctx) {
+		s = TraceStore(ctx, s)
+	}
+	return s, ok
+}
+
+// MakeStoreMiddleware create a middleware adding s to the request's
+// context, it is the Store used by the page handlers.
+func MakeStoreMiddleware(s Store) Middleware {
+	return func(h Handler) Handler {
+		return HandlerFunc(func(w http.ResponseWriter, r *http.Request) (int, error) {
+			ctx := NewContextWithStore(r.Context(), s)
+			return h.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
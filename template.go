@@ -2,9 +2,18 @@ package mngr
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
 	"html/template"
+	"io"
+	"io/fs"
 	"net/http"
+	"os"
+	"path"
+	"path/filepath"
 	"strings"
+	"sync"
 
 	"github.com/russross/blackfriday"
 )
@@ -18,6 +27,24 @@ type (
 		Value  string
 		Dir    string
 		IsDir  bool
+		// Title is the human title of the page, when one could be derived.
+		Title string
+		// Nonce is the request's Content-Security-Policy nonce, to be
+		// set on inline scripts and styles.
+		Nonce string
+		// User is the name of the logged in user, if any.
+		User string
+		// ReadOnly is set when the wiki can't be modified.
+		ReadOnly bool
+		// CSRFToken is the token the forms must send back, see
+		// MakeCSRFMiddleware.
+		CSRFToken string
+		// Base is the path under which the wiki is mounted, to prefix the
+		// links with, see MakeBasePathMiddleware.
+		Base string
+		// Lang is the language of the page, to translate the messages
+		// with, see MakeLanguageMiddleware.
+		Lang string
 	}
 )
 
@@ -31,30 +58,273 @@ func NewTemplateFromValidURL(v ValidURL) TemplateInfo {
 	}
 }
 
+// newTemplateInfo create the TemplateInfo of a request.
+func newTemplateInfo(r *http.Request, v ValidURL) TemplateInfo {
+	info := NewTemplateFromValidURL(v)
+	info.fromRequest(r)
+	return info
+}
+
+// fromRequest set the fields of info coming from the request's context.
+func (info *TemplateInfo) fromRequest(r *http.Request) {
+	info.Nonce = NonceFromCtx(r.Context())
+	info.User, _ = UserFromCtx(r.Context())
+	info.ReadOnly = readOnlyFromCtx(r.Context())
+	info.CSRFToken = CSRFTokenFromCtx(r.Context())
+	info.Base = BasePathFromCtx(r.Context())
+	info.Lang = LangFromCtx(r.Context())
+}
+
+// Templates holds the compiled page templates. Every page is rendered
+// through a shared layout which includes the page "content" block.
+type Templates struct {
+	layout string
+	pages  map[string]*template.Template
+	// version changes when the templates are compiled again, see
+	// MakeReloadTemplateMiddleware.
+	version string
+	// ctx is the context of the request rendering the templates, set when
+	// the rendering is traced.
+	ctx context.Context
+}
+
+// ExecuteTemplate render the page template name with data and write
+// the output to w.
+func (t *Templates) ExecuteTemplate(w io.Writer, name string, data interface{}) (err error) {
+	p, ok := t.pages[name]
+	if !ok {
+		return fmt.Errorf("mngr: no template named %q", name)
+	}
+	if t.ctx != nil {
+		_, end := StartSpan(t.ctx, "template "+name)
+		defer func() { end(err) }()
+	}
+	return p.ExecuteTemplate(w, t.layout, data)
+}
+
+// has report whether t holds the page template name.
+func (t *Templates) has(name string) bool {
+	_, ok := t.pages[name]
+	return ok
+}
+
+// NewContextWithTemplate return a copy of ctx holding t, like the template
+// middlewares do, for the handlers to read with TemplateFromCtx. See
+// LoadTemplates.
+func NewContextWithTemplate(ctx context.Context, t *Templates) context.Context {
+	return context.WithValue(ctx, templateKey, t)
+}
+
 // TemplateFromCtx extract templates added by MakeTemplateMiddleware to a context.
-func TemplateFromCtx(c context.Context) (*template.Template, bool) {
-	t, ok := c.Value(templateKey).(*template.Template)
+// When the context holds a Tracer, the rendering of the templates is traced.
+func TemplateFromCtx(c context.Context) (*Templates, bool) {
+	t, ok := c.Value(templateKey).(*Templates)
+	if ok && traced(c) {
+		bound := *t
+		bound.ctx = c
+		t = &bound
+	}
 	return t, ok
 }
 
+// loadTemplates compile every page located in '*.html' of pages with the
+// layout of layoutFS and the partials located in 'partial/*.html' of pages.
+// The layout file is not considered as a page, even when it belongs to
+// pages.
+func loadTemplates(pages, layoutFS fs.FS, layout string, funcs template.FuncMap) (*Templates, error) {
+	base, err := template.New("main").Funcs(funcs).ParseFS(layoutFS, layout)
+	if err != nil {
+		return nil, err
+	}
+	base, err = base.ParseFS(pages, "partial/*.html")
+	if err != nil {
+		return nil, err
+	}
+	files, err := fs.Glob(pages, "*.html")
+	if err != nil {
+		return nil, err
+	}
+	layoutName := path.Base(layout)
+	t := &Templates{
+		layout: layoutName,
+		pages:  make(map[string]*template.Template, len(files)),
+	}
+	for _, name := range files {
+		if name == layoutName {
+			continue
+		}
+		page, err := base.Clone()
+		if err != nil {
+			return nil, err
+		}
+		t.pages[name], err = page.ParseFS(pages, name)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return t, nil
+}
+
 // MakeTemplateMiddleware load an compile all templates located in 'path/*.html' and 'path/partial/*.html'.
+// Pages are rendered through the default layout, 'path/layout.html'.
 // When plugged, the returned middleware add templates to the request's context.
-func MakeTemplateMiddleware(path string) Middleware {
-	var tmplFunc = template.FuncMap{
-		"renderMD": func(data []byte) template.HTML {
-			return template.HTML(blackfriday.MarkdownCommon(data))
-		},
-		"fmtTitle": func(title string) string {
-			return strings.Title(title)
-		},
-	}
-	templates := template.Must(template.New("main").Funcs(tmplFunc).ParseGlob(path + "/*.html"))
-	templates = template.Must(templates.ParseGlob(path + "/partial/*.html"))
+func MakeTemplateMiddleware(path string, opts ...TemplateOption) Middleware {
+	return MakeLayoutTemplateMiddleware(path, path+"/layout.html", opts...)
+}
+
+// TemplateOption configures the template middlewares.
+type TemplateOption func(*templateConfig)
+
+type templateConfig struct {
+	funcs template.FuncMap
+}
+
+// TemplateFuncs make the functions of funcs available to the templates, in
+// addition to those of mngr, like renderMD and fmtTitle, which a function
+// of the same name replace. The functions follow the rules of
+// template.FuncMap.
+func TemplateFuncs(funcs map[string]interface{}) TemplateOption {
+	return func(c *templateConfig) {
+		for name, fn := range funcs {
+			c.funcs[name] = fn
+		}
+	}
+}
+
+// TemplateCatalog make the templates translate their messages with c, in
+// place of DefaultCatalog: '{{translate .Lang "message"}}'.
+func TemplateCatalog(c *Catalog) TemplateOption {
+	return func(tc *templateConfig) {
+		tc.funcs["translate"] = c.Translate
+	}
+}
+
+// newTemplateConfig return the configuration of the options opts.
+func newTemplateConfig(opts []TemplateOption) templateConfig {
+	c := templateConfig{funcs: make(template.FuncMap, len(templateFuncs))}
+	for name, fn := range templateFuncs {
+		c.funcs[name] = fn
+	}
+	for _, opt := range opts {
+		opt(&c)
+	}
+	return c
+}
+
+// templateFuncs are the functions available to the templates.
+var templateFuncs = template.FuncMap{
+	"renderMD": func(data []byte) template.HTML {
+		return template.HTML(blackfriday.MarkdownCommon(data))
+	},
+	"fmtTitle": func(title string) string {
+		return strings.Title(title)
+	},
+	"translate": DefaultCatalog.Translate,
+}
+
+// MakeLayoutTemplateMiddleware works like MakeTemplateMiddleware but render
+// the pages through the given layout file. A layout must include the page
+// with '{{template "content" .}}'.
+func MakeLayoutTemplateMiddleware(path, layout string, opts ...TemplateOption) Middleware {
+	return makeTemplateMiddleware(os.DirFS(path), os.DirFS(filepath.Dir(layout)), filepath.Base(layout), opts)
+}
+
+// MakeFSTemplateMiddleware works like MakeTemplateMiddleware with the
+// templates of fsys, like DefaultTemplates: the pages '*.html', the
+// partials 'partial/*.html' and the layout 'layout.html'.
+func MakeFSTemplateMiddleware(fsys fs.FS, opts ...TemplateOption) Middleware {
+	return makeTemplateMiddleware(fsys, fsys, "layout.html", opts)
+}
+
+// makeTemplateMiddleware create the middleware of MakeLayoutTemplateMiddleware,
+// with the pages and partials of pages and the layout of layoutFS.
+func makeTemplateMiddleware(pages, layoutFS fs.FS, layout string, opts []TemplateOption) Middleware {
+	c := newTemplateConfig(opts)
+	templates, err := loadTemplates(pages, layoutFS, layout, c.funcs)
+	if err != nil {
+		panic(err)
+	}
+	return MakeCompiledTemplateMiddleware(templates)
+}
+
+// LoadTemplates compile the templates of fsys, laid out like those of
+// MakeFSTemplateMiddleware, returning the error of those which don't
+// compile.
+func LoadTemplates(fsys fs.FS, opts ...TemplateOption) (*Templates, error) {
+	return loadTemplates(fsys, fsys, "layout.html", newTemplateConfig(opts).funcs)
+}
+
+// MakeCompiledTemplateMiddleware create a middleware adding the compiled
+// templates t to the request's context, see LoadTemplates.
+func MakeCompiledTemplateMiddleware(t *Templates) Middleware {
+	return func(h Handler) Handler {
+		return HandlerFunc(func(w http.ResponseWriter, r *http.Request) (int, error) {
+			ctx := NewContextWithTemplate(r.Context(), t)
+			return h.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// templateReloader holds templates compiled again when their files change.
+type templateReloader struct {
+	fsys  fs.FS
+	funcs template.FuncMap
+
+	mu        sync.Mutex
+	templates *Templates
+	// stamp describe the files the templates were compiled from.
+	stamp string
+}
+
+// templateStamp return the names, sizes and modification times of the
+// template files of l.
+func (l *templateReloader) templateStamp() string {
+	pages, _ := fs.Glob(l.fsys, "*.html")
+	partials, _ := fs.Glob(l.fsys, "partial/*.html")
+	var b strings.Builder
+	for _, name := range append(pages, partials...) {
+		fi, err := fs.Stat(l.fsys, name)
+		if err != nil {
+			continue
+		}
+		fmt.Fprintf(&b, "%s %d %d\n", name, fi.Size(), fi.ModTime().UnixNano())
+	}
+	return b.String()
+}
+
+// get return the templates, compiled again when a file changed since the
+// last call.
+func (l *templateReloader) get() (*Templates, error) {
+	stamp := l.templateStamp()
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.templates != nil && stamp == l.stamp {
+		return l.templates, nil
+	}
+	t, err := loadTemplates(l.fsys, l.fsys, "layout.html", l.funcs)
+	if err != nil {
+		return nil, err
+	}
+	sum := sha256.Sum256([]byte(stamp))
+	t.version = hex.EncodeToString(sum[:8])
+	l.templates, l.stamp = t, stamp
+	return t, nil
+}
 
+// MakeReloadTemplateMiddleware works like MakeFSTemplateMiddleware but
+// compile the templates again when one of their files changed, checked on
+// every request, so themes can be edited without restarting the server.
+// Requests fail with the error of templates which don't compile. It is
+// meant for development, use MakeFSTemplateMiddleware otherwise.
+func MakeReloadTemplateMiddleware(fsys fs.FS, opts ...TemplateOption) Middleware {
+	l := &templateReloader{fsys: fsys, funcs: newTemplateConfig(opts).funcs}
 	return func(h Handler) Handler {
 		return HandlerFunc(func(w http.ResponseWriter, r *http.Request) (int, error) {
-			ctx := r.Context()
-			ctx = context.WithValue(ctx, templateKey, templates)
+			templates, err := l.get()
+			if err != nil {
+				return 0, err
+			}
+			ctx := NewContextWithTemplate(r.Context(), templates)
 			return h.ServeHTTP(w, r.WithContext(ctx))
 		})
 	}
@@ -0,0 +1,40 @@
+package mngr
+
+import (
+	"strings"
+	"unicode"
+)
+
+// tokenize split the content of a page in lower case words, ignoring its
+// front matter and punctuation.
+func tokenize(body []byte) []string {
+	_, content := splitFrontMatter(body)
+	return strings.FieldsFunc(strings.ToLower(string(content)), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+}
+
+// jaccard return the Jaccard index of the word sets of a and b: the number
+// of distinct words found in both divided by the number of distinct words
+// found in either. Two empty sets are identical.
+func jaccard(a, b []string) float64 {
+	setA := make(map[string]bool, len(a))
+	for _, w := range a {
+		setA[w] = true
+	}
+	setB := make(map[string]bool, len(b))
+	for _, w := range b {
+		setB[w] = true
+	}
+	both := 0
+	for w := range setB {
+		if setA[w] {
+			both++
+		}
+	}
+	either := len(setA) + len(setB) - both
+	if either == 0 {
+		return 1
+	}
+	return float64(both) / float64(either)
+}
@@ -0,0 +1,25 @@
+package mngr
+
+import (
+	"context"
+	"net/http"
+	"time"
+)
+
+// MakeTimeoutMiddleware create a middleware giving every request a deadline
+// of d: the operations given the context of the request, like the walks of
+// the Store or the searches, stop once it passes. The handlers returning
+// the context.DeadlineExceeded error are answered with 503, see
+// DefaultErrorStatus. A d of 0 disables the middleware.
+func MakeTimeoutMiddleware(d time.Duration) Middleware {
+	return func(h Handler) Handler {
+		if d <= 0 {
+			return h
+		}
+		return HandlerFunc(func(w http.ResponseWriter, r *http.Request) (int, error) {
+			ctx, cancel := context.WithTimeout(r.Context(), d)
+			defer cancel()
+			return h.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
@@ -0,0 +1,81 @@
+package mngr
+
+import (
+	"context"
+	"net/http"
+	"time"
+)
+
+type touchedPage struct {
+	Path string    `json:"path"`
+	Date time.Time `json:"date"`
+}
+
+type touchError struct {
+	Path  string `json:"path"`
+	Error string `json:"error"`
+}
+
+// MakeTouchHandler return an handler setting the modification time of the
+// pages located under the requested folder to the 'date' of their front
+// matter. Unless the request is a POST with a non empty 'apply' value, it
+// is a dry run only reporting what would change. The JSON response lists
+// the updated pages and the dates which couldn't be parsed. Applying
+// requires s to be a Toucher.
+func MakeTouchHandler(s Store, workers int) ContextHandlerFunc {
+	return func(ctx context.Context, w http.ResponseWriter, r *http.Request) (int, error) {
+		valid, _ := ValidURLFromCtx(ctx)
+		apply := r.Method == http.MethodPost && r.FormValue("apply") != ""
+		toucher, ok := s.(Toucher)
+		if apply && !ok {
+			w.Header().Set("Content-Type", "text/plain")
+			w.WriteHeader(http.StatusNotImplemented)
+			w.Write([]byte("the store can't change modification times"))
+			return http.StatusNotImplemented, nil
+		}
+		pages := []touchedPage{}
+		failures := []touchError{}
+		err := walkPages(ctx, s, valid.Dir, readFilter(ctx), workers, func(path string, body []byte) error {
+			if !isText(body) {
+				return nil
+			}
+			date := ParseFrontMatter(body)["date"]
+			if date == "" {
+				return nil
+			}
+			t, err := ParseDate(date)
+			if err != nil {
+				failures = append(failures, touchError{Path: path, Error: err.Error()})
+				return nil
+			}
+			fi, err := s.Stat(path)
+			if err != nil {
+				return err
+			}
+			if fi.ModTime().Equal(t) {
+				return nil
+			}
+			if apply {
+				if err := toucher.Touch(path, t); err != nil {
+					return err
+				}
+			}
+			pages = append(pages, touchedPage{Path: path, Date: t})
+			return nil
+		})
+		if err != nil {
+			return 0, err
+		}
+		return writeJSON(w, http.StatusOK, struct {
+			DryRun  bool          `json:"dryRun"`
+			Updated int           `json:"updated"`
+			Pages   []touchedPage `json:"pages"`
+			Errors  []touchError  `json:"errors"`
+		}{
+			DryRun:  !apply,
+			Updated: len(pages),
+			Pages:   pages,
+			Errors:  failures,
+		})
+	}
+}
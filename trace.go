@@ -0,0 +1,218 @@
+package mngr
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"os"
+	"time"
+)
+
+type tracerCtxKey int
+
+var tracerKey = tracerCtxKey(0)
+
+// Tracer start the spans tracing the work done for the requests. The
+// oteltrace package implements it with OpenTelemetry.
+type Tracer interface {
+	// Start begin a span named name, child of the span of ctx if any. The
+	// returned function ends the span, recording err when it isn't nil.
+	Start(ctx context.Context, name string) (context.Context, func(err error))
+}
+
+// MakeTracerMiddleware create a middleware adding t to the request's
+// context. The templates and the Store then trace their work in child
+// spans of the request, and handlers can do the same with StartSpan.
+func MakeTracerMiddleware(t Tracer) Middleware {
+	return func(h Handler) Handler {
+		return HandlerFunc(func(w http.ResponseWriter, r *http.Request) (int, error) {
+			ctx := context.WithValue(r.Context(), tracerKey, t)
+			return h.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// StartSpan begin a span named name with the Tracer added to ctx by
+// MakeTracerMiddleware. Without Tracer, it return ctx and a function doing
+// nothing.
+func StartSpan(ctx context.Context, name string) (context.Context, func(err error)) {
+	t, ok := ctx.Value(tracerKey).(Tracer)
+	if !ok {
+		return ctx, func(error) {}
+	}
+	return t.Start(ctx, name)
+}
+
+// traced report whether ctx holds a Tracer.
+func traced(ctx context.Context) bool {
+	_, ok := ctx.Value(tracerKey).(Tracer)
+	return ok
+}
+
+// TraceStore return a Store tracing every operation on s in a child span
+// of ctx. The Renamer, Toucher and Versioned interfaces of s are kept.
+func TraceStore(ctx context.Context, s Store) Store {
+	t := &tracedStore{ctx: ctx, s: s}
+	r, isRenamer := s.(Renamer)
+	to, isToucher := s.(Toucher)
+	v, isVersioned := s.(Versioned)
+	tr, tt, tv := tracedRenamer{t, r}, tracedToucher{t, to}, tracedVersioned{t, v}
+	switch {
+	case isRenamer && isToucher && isVersioned:
+		return struct {
+			*tracedStore
+			tracedRenamer
+			tracedToucher
+			tracedVersioned
+		}{t, tr, tt, tv}
+	case isRenamer && isToucher:
+		return struct {
+			*tracedStore
+			tracedRenamer
+			tracedToucher
+		}{t, tr, tt}
+	case isRenamer && isVersioned:
+		return struct {
+			*tracedStore
+			tracedRenamer
+			tracedVersioned
+		}{t, tr, tv}
+	case isToucher && isVersioned:
+		return struct {
+			*tracedStore
+			tracedToucher
+			tracedVersioned
+		}{t, tt, tv}
+	case isRenamer:
+		return struct {
+			*tracedStore
+			tracedRenamer
+		}{t, tr}
+	case isToucher:
+		return struct {
+			*tracedStore
+			tracedToucher
+		}{t, tt}
+	case isVersioned:
+		return struct {
+			*tracedStore
+			tracedVersioned
+		}{t, tv}
+	}
+	return t
+}
+
+// tracedStore is the Store returned by TraceStore.
+type tracedStore struct {
+	ctx context.Context
+	s   Store
+}
+
+// WithContext implements ContextStore, keeping the traces in the span of
+// t.
+func (t *tracedStore) WithContext(ctx context.Context) Store {
+	return TraceStore(t.ctx, StoreWithContext(ctx, t.s))
+}
+
+// span run f in a span named after the operation op. The names of the files
+// are left out, to keep a small set of span names.
+func (t *tracedStore) span(op string, f func() error) error {
+	_, end := StartSpan(t.ctx, "store."+op)
+	err := f()
+	end(err)
+	return err
+}
+
+func (t *tracedStore) Read(name string) (body []byte, err error) {
+	err = t.span("Read", func() error {
+		body, err = t.s.Read(name)
+		return err
+	})
+	return body, err
+}
+
+func (t *tracedStore) Write(name string, body []byte) error {
+	return t.span("Write", func() error { return t.s.Write(name, body) })
+}
+
+func (t *tracedStore) List(name string) (fInfos []os.FileInfo, err error) {
+	err = t.span("List", func() error {
+		fInfos, err = t.s.List(name)
+		return err
+	})
+	return fInfos, err
+}
+
+func (t *tracedStore) Mkdir(name string) error {
+	return t.span("Mkdir", func() error { return t.s.Mkdir(name) })
+}
+
+func (t *tracedStore) Remove(name string) error {
+	return t.span("Remove", func() error { return t.s.Remove(name) })
+}
+
+func (t *tracedStore) Stat(name string) (fi os.FileInfo, err error) {
+	err = t.span("Stat", func() error {
+		fi, err = t.s.Stat(name)
+		return err
+	})
+	return fi, err
+}
+
+// Open implements Opener, for the stores which aren't too, see openFile.
+func (t *tracedStore) Open(name string) (f io.ReadSeekCloser, err error) {
+	err = t.span("Open", func() error {
+		f, err = openFile(t.s, name)
+		return err
+	})
+	return f, err
+}
+
+// Create implements Creator, for the stores which aren't too, see
+// createFile.
+func (t *tracedStore) Create(name string, excl bool) (f io.WriteCloser, err error) {
+	err = t.span("Create", func() error {
+		f, err = createFile(t.s, name, excl)
+		return err
+	})
+	return f, err
+}
+
+type tracedRenamer struct {
+	t *tracedStore
+	r Renamer
+}
+
+func (t tracedRenamer) Rename(from, to string) error {
+	return t.t.span("Rename", func() error { return t.r.Rename(from, to) })
+}
+
+type tracedToucher struct {
+	t  *tracedStore
+	to Toucher
+}
+
+func (t tracedToucher) Touch(name string, mtime time.Time) error {
+	return t.t.span("Touch", func() error { return t.to.Touch(name, mtime) })
+}
+
+type tracedVersioned struct {
+	t *tracedStore
+	v Versioned
+}
+
+func (t tracedVersioned) History(name string) (revs []Revision, err error) {
+	err = t.t.span("History", func() error {
+		revs, err = t.v.History(name)
+		return err
+	})
+	return revs, err
+}
+
+func (t tracedVersioned) ReadRevision(name, id string) (body []byte, err error) {
+	err = t.t.span("ReadRevision", func() error {
+		body, err = t.v.ReadRevision(name, id)
+		return err
+	})
+	return body, err
+}
@@ -0,0 +1,205 @@
+package mngr
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// trashDir is the hidden folder, at the root of the store, keeping the
+// deleted files and folders until they are purged.
+const trashDir = ".trash"
+
+// TrashItem describe a file or a folder moved to the trash.
+type TrashItem struct {
+	ID      string    `json:"id"`
+	Path    string    `json:"path"`
+	IsDir   bool      `json:"isDir"`
+	Deleted time.Time `json:"deleted"`
+}
+
+// trashPath return the path of the trashed item id, its description is
+// stored next to it in a .json file.
+func trashPath(id string) string {
+	return trashDir + "/" + id
+}
+
+// moveToTrash move the file or folder name of s to the trash.
+func moveToTrash(s Store, name string) error {
+	fi, err := s.Stat(name)
+	if err != nil {
+		return err
+	}
+	err = s.Mkdir(trashDir)
+	if err != nil && !os.IsExist(err) {
+		return err
+	}
+	now := time.Now()
+	item := TrashItem{
+		ID:      strconv.FormatInt(now.UnixNano(), 10),
+		Path:    strings.TrimPrefix(path.Clean("/"+name), "/"),
+		IsDir:   fi.IsDir(),
+		Deleted: now,
+	}
+	body, err := json.Marshal(item)
+	if err != nil {
+		return err
+	}
+	if err := rename(s, name, trashPath(item.ID)); err != nil {
+		return err
+	}
+	return s.Write(trashPath(item.ID)+".json", body)
+}
+
+// trashItems return the content of the trash, most recently deleted first.
+func trashItems(s Store) ([]TrashItem, error) {
+	fInfos, err := s.List(trashDir)
+	if os.IsNotExist(err) {
+		return []TrashItem{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	items := []TrashItem{}
+	for _, fi := range fInfos {
+		if !strings.HasSuffix(fi.Name(), ".json") {
+			continue
+		}
+		body, err := s.Read(trashDir + "/" + fi.Name())
+		if err != nil {
+			return nil, err
+		}
+		var item TrashItem
+		if err := json.Unmarshal(body, &item); err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+	sort.Slice(items, func(i, j int) bool {
+		return items[i].Deleted.After(items[j].Deleted)
+	})
+	return items, nil
+}
+
+// loadTrashItem return the description of the trashed item id.
+func loadTrashItem(s Store, id string) (*TrashItem, error) {
+	body, err := s.Read(trashPath(id) + ".json")
+	if err != nil {
+		return nil, err
+	}
+	item := &TrashItem{}
+	return item, json.Unmarshal(body, item)
+}
+
+// mkdirAll create the folder name of s and its missing parents.
+func mkdirAll(s Store, name string) error {
+	name = strings.Trim(path.Clean("/"+name), "/")
+	if name == "" {
+		return nil
+	}
+	if fi, err := s.Stat(name); err == nil {
+		if !fi.IsDir() {
+			return &os.PathError{Op: "mkdir", Path: name, Err: os.ErrExist}
+		}
+		return nil
+	}
+	if err := mkdirAll(s, path.Dir(name)); err != nil {
+		return err
+	}
+	return s.Mkdir(name)
+}
+
+// restoreTrashItem move the trashed item id back to its original path,
+// recreating its parent folders. It fails when the path is taken, or isn't
+// a valid path, in case the metadata of the item was altered.
+func restoreTrashItem(s Store, id string) (*TrashItem, error) {
+	item, err := loadTrashItem(s, id)
+	if err != nil {
+		return nil, err
+	}
+	if !ValidPath(item.Path) {
+		return nil, NewHTTPError(http.StatusBadRequest, fmt.Errorf("restore %s: invalid path %q", id, item.Path))
+	}
+	if _, err := s.Stat(item.Path); err == nil {
+		return nil, &os.PathError{Op: "restore", Path: item.Path, Err: os.ErrExist}
+	}
+	if err := mkdirAll(s, path.Dir(item.Path)); err != nil {
+		return nil, err
+	}
+	if err := rename(s, trashPath(id), item.Path); err != nil {
+		return nil, err
+	}
+	return item, s.Remove(trashPath(id) + ".json")
+}
+
+// purgeTrashItem permanently delete the trashed item id.
+func purgeTrashItem(s Store, id string) error {
+	if _, err := loadTrashItem(s, id); err != nil {
+		return err
+	}
+	if err := s.Remove(trashPath(id)); err != nil {
+		return err
+	}
+	return s.Remove(trashPath(id) + ".json")
+}
+
+// TrashHandler is an handler managing the trash, using trash.html.
+// GET requests list the deleted files and folders. POST requests restore
+// or purge the item given by the 'id' value, depending on the 'action'
+// value being "restore" or "purge".
+func TrashHandler(w http.ResponseWriter, r *http.Request) (int, error) {
+	s, _ := StoreFromCtx(r.Context())
+	if r.Method == http.MethodPost {
+		id := r.FormValue("id")
+		if !validName.MatchString(id) {
+			w.Header().Set("Content-Type", "text/plain")
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte("bad request: invalid id"))
+			return http.StatusBadRequest, nil
+		}
+		switch r.FormValue("action") {
+		case "restore":
+			item, err := restoreTrashItem(s, id)
+			if err != nil {
+				return 0, err
+			}
+			url := "/view/" + item.Path
+			if item.IsDir {
+				url = "/list/" + item.Path + "/"
+			}
+			redirect(w, r, url, http.StatusFound)
+			return http.StatusFound, nil
+		case "purge":
+			if err := purgeTrashItem(s, id); err != nil {
+				return 0, err
+			}
+			redirect(w, r, "/trash/", http.StatusFound)
+			return http.StatusFound, nil
+		}
+		w.Header().Set("Content-Type", "text/plain")
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte("bad request: invalid action"))
+		return http.StatusBadRequest, nil
+	}
+
+	items, err := trashItems(s)
+	if err != nil {
+		return 0, err
+	}
+	v := &struct {
+		TemplateInfo
+		Items []TrashItem
+	}{
+		TemplateInfo: newTemplateInfo(r, ValidURL{Action: "trash"}),
+		Items:        items,
+	}
+	t, _ := TemplateFromCtx(r.Context())
+	err = t.ExecuteTemplate(w, "trash.html", v)
+	return 200, err
+}
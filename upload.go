@@ -0,0 +1,106 @@
+package mngr
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"path"
+)
+
+// errTooLarge is returned by copyPart when a file exceeds its size limit.
+var errTooLarge = errors.New("file too large")
+
+// copyPart stream the content of an uploaded part to a new file of s called
+// name, the file must not already exist. When the part is larger than max
+// bytes the file is discarded and errTooLarge is returned.
+func copyPart(s Store, name string, part io.Reader, max int64) error {
+	f, err := createFile(s, name, true)
+	if err != nil {
+		return err
+	}
+	n, err := io.Copy(f, io.LimitReader(part, max+1))
+	if err == nil && n > max {
+		err = errTooLarge
+	}
+	if err != nil {
+		discardFile(s, name, f)
+		return err
+	}
+	return f.Close()
+}
+
+// MakeUploadHandler return an handler which store the files of a
+// multipart/form-data request in the requested folder. GET requests display
+// the upload form. Each file must be smaller than maxFileSize bytes and the
+// whole request smaller than maxRequestSize. File names follow the same
+// rules as MakeNewHandler and existing files are never overwritten. When a
+// name is invalid the form is displayed again, the files uploaded before it
+// are kept. The files are written to the Store of the request's context,
+// see StoreFromCtx.
+func MakeUploadHandler(maxFileSize, maxRequestSize int64) HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) (int, error) {
+		valid, _ := ValidURLFromCtx(r.Context())
+		s, _ := StoreFromCtx(r.Context())
+		isValid := true
+		if r.Method == http.MethodPost {
+			if r.ContentLength > maxRequestSize {
+				w.Header().Set("Content-Type", "text/plain")
+				w.WriteHeader(http.StatusRequestEntityTooLarge)
+				w.Write([]byte("request entity too large"))
+				return http.StatusRequestEntityTooLarge, nil
+			}
+			r.Body = http.MaxBytesReader(w, r.Body, maxRequestSize)
+			mr, err := r.MultipartReader()
+			if err != nil {
+				w.Header().Set("Content-Type", "text/plain")
+				w.WriteHeader(http.StatusBadRequest)
+				w.Write([]byte("bad request"))
+				return http.StatusBadRequest, nil
+			}
+			for isValid {
+				part, err := mr.NextPart()
+				if err == io.EOF {
+					break
+				}
+				if err != nil {
+					return 0, err
+				}
+				if part.FileName() == "" {
+					continue
+				}
+				name := path.Base(part.FileName())
+				isValid = validName.MatchString(name)
+				if !isValid {
+					break
+				}
+				err = copyPart(s, valid.Dir+name, part, maxFileSize)
+				if err == errTooLarge {
+					w.Header().Set("Content-Type", "text/plain")
+					w.WriteHeader(http.StatusRequestEntityTooLarge)
+					w.Write([]byte(name + " is too large"))
+					return http.StatusRequestEntityTooLarge, nil
+				}
+				if err != nil {
+					return 0, err
+				}
+			}
+			if isValid {
+				redirect(w, r, "/list/"+valid.Dir, http.StatusFound)
+				return http.StatusFound, nil
+			}
+		}
+
+		p := &struct {
+			TemplateInfo
+			MaxFileSize int64
+			IsValid     bool
+		}{
+			TemplateInfo: newTemplateInfo(r, valid),
+			MaxFileSize:  maxFileSize,
+			IsValid:      isValid,
+		}
+		t, _ := TemplateFromCtx(r.Context())
+		err := t.ExecuteTemplate(w, "upload.html", p)
+		return 200, err
+	}
+}
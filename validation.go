@@ -3,7 +3,6 @@ package mngr
 import (
 	"context"
 	"net/http"
-	"os"
 	"regexp"
 	"strings"
 )
@@ -26,14 +25,43 @@ type (
 
 var (
 	validURLKey = validURLCtxKey(0)
+	// validPagePath match the path of a page relative to the data folder,
+	// with the same rules as MakeValidURLMiddleware.
+	validPagePath = regexp.MustCompile("^[a-zA-Z0-9/]*[a-zA-Z0-9]+[a-zA-Z0-9.]*$")
+	// validName match the names accepted for new files and folders.
+	validName = regexp.MustCompile("^[a-zA-Z0-9]+[a-zA-Z0-9.]*$")
 )
 
+// ValidPath report whether p, relative to the data folder, names a page or
+// a folder reachable from the web interface: it follows the rules of
+// MakeValidURLMiddleware and none of its segments is hidden, like the
+// '.trash' and '.snapshots' folders.
+func ValidPath(p string) bool {
+	if !validPagePath.MatchString(p) {
+		return false
+	}
+	for _, seg := range strings.Split(p, "/") {
+		if strings.HasPrefix(seg, ".") {
+			return false
+		}
+	}
+	return true
+}
+
 // ValidURLFromCtx extract a ValidURL added by MakeValidURLMiddleware from a context.
 func ValidURLFromCtx(ctx context.Context) (ValidURL, bool) {
 	valid, ok := ctx.Value(validURLKey).(ValidURL)
 	return valid, ok
 }
 
+// NewContextWithValidURL return a copy of ctx holding v, like the
+// validation middlewares do, for the handlers to read with
+// ValidURLFromCtx. It lets tests and custom routers drive the page
+// handlers directly.
+func NewContextWithValidURL(ctx context.Context, v ValidURL) context.Context {
+	return context.WithValue(ctx, validURLKey, v)
+}
+
 // findFolder separate folder and file in the path.
 // Folder will be empty if there is only a file.
 func findFolder(path string) (file, folder string) {
@@ -62,7 +90,7 @@ func MakeValidURLMiddleware() Middleware {
 			}
 			file, folder := findFolder(m[2])
 			ctx := r.Context()
-			ctx = context.WithValue(ctx, validURLKey, ValidURL{
+			ctx = NewContextWithValidURL(ctx, ValidURL{
 				Action: m[1],
 				Value:  file,
 				Dir:    folder,
@@ -76,17 +104,17 @@ func MakeValidURLMiddleware() Middleware {
 // When plugged, the returned middleware will look for a valid URL and
 // an existing folder on disk. It will also add a ValidURL to the request's
 // context.
-func MakeValidFolderMiddleware(dataPath string) Middleware {
+func MakeValidFolderMiddleware(s Store) Middleware {
 	validPath := regexp.MustCompile("^/([a-z]+)/([a-zA-Z0-9/]*)$")
 	return func(h Handler) Handler {
 		return HandlerFunc(func(w http.ResponseWriter, r *http.Request) (int, error) {
 			m := validPath.FindStringSubmatch(r.URL.Path)
 			path := m[2]
 			if len(path) != 0 && path[len(path)-1] != '/' {
-				http.Redirect(w, r, r.URL.Path+"/", http.StatusFound)
+				redirect(w, r, r.URL.Path+"/", http.StatusFound)
 				return http.StatusFound, nil
 			}
-			f, err := os.Stat(dataPath + "/" + path)
+			f, err := s.Stat(path)
 			if err != nil {
 				w.Header().Set("Content-Type", "text/plain")
 				w.WriteHeader(http.StatusNotFound)
@@ -101,7 +129,7 @@ func MakeValidFolderMiddleware(dataPath string) Middleware {
 			}
 
 			ctx := r.Context()
-			ctx = context.WithValue(ctx, validURLKey, ValidURL{
+			ctx = NewContextWithValidURL(ctx, ValidURL{
 				Action: m[1],
 				Dir:    m[2],
 			})
@@ -0,0 +1,130 @@
+package mngr
+
+import (
+	"context"
+	"sync"
+)
+
+// walkFunc is called by walkFiles for every file found, path being relative
+// to the root of the walked store.
+type walkFunc func(path string) error
+
+// walkFiles call fn for every file located under dir in s, in
+// alphabetical order with the files of a folder before its sub-folders.
+// Hidden files and folders are skipped, like in listings, and so are the
+// files and folders for which readable, when not nil, return false, see
+// readFilter. The walk stops on the first error returned by fn or when ctx
+// is done, which also cancels the operations of a ContextStore.
+func walkFiles(ctx context.Context, s Store, dir string, readable func(p string) bool, fn walkFunc) error {
+	return walkStore(ctx, StoreWithContext(ctx, s), dir, readable, fn)
+}
+
+// walkStore is walkFiles with s bound to ctx.
+func walkStore(ctx context.Context, s Store, dir string, readable func(p string) bool, fn walkFunc) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	fInfos, err := s.List(dir)
+	if err != nil {
+		return err
+	}
+	files, folders := filterFiles(fInfos)
+	for _, name := range files {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if readable != nil && !readable(dir+name) {
+			continue
+		}
+		if err := fn(dir + name); err != nil {
+			return err
+		}
+	}
+	for _, name := range folders {
+		if readable != nil && !readable(dir+name) {
+			continue
+		}
+		if err := walkStore(ctx, s, dir+name+"/", readable, fn); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type readResult struct {
+	body []byte
+	err  error
+}
+
+type readJob struct {
+	path string
+	res  chan readResult
+}
+
+// walkPages call fn with the path and content of every file located under
+// dir in s, skipping those of walkFiles. Files are read in parallel by up
+// to workers goroutines but fn is always called sequentially, in the order
+// of walkFiles. The walk stops on the first error and every worker exits
+// before walkPages return.
+func walkPages(ctx context.Context, s Store, dir string, readable func(p string) bool, workers int, fn func(path string, body []byte) error) error {
+	if workers < 1 {
+		workers = 1
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	s = StoreWithContext(ctx, s)
+	jobs := make(chan readJob)
+	order := make(chan readJob, workers)
+	var walkErr error
+	go func() {
+		defer close(jobs)
+		defer close(order)
+		walkErr = walkFiles(ctx, s, dir, readable, func(path string) error {
+			job := readJob{path: path, res: make(chan readResult, 1)}
+			select {
+			case order <- job:
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+			select {
+			case jobs <- job:
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+			return nil
+		})
+	}()
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for job := range jobs {
+				body, err := s.Read(job.path)
+				job.res <- readResult{body: body, err: err}
+			}
+		}()
+	}
+	stop := func() {
+		cancel()
+		wg.Wait()
+	}
+
+	for job := range order {
+		var res readResult
+		select {
+		case res = <-job.res:
+		case <-ctx.Done():
+			stop()
+			return ctx.Err()
+		}
+		if res.err == nil {
+			res.err = fn(job.path, res.body)
+		}
+		if res.err != nil {
+			stop()
+			return res.err
+		}
+	}
+	stop()
+	return walkErr
+}
@@ -0,0 +1,85 @@
+package mngr
+
+import (
+	"context"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// DefaultStopWords contains common English words without meaning on their own.
+var DefaultStopWords = []string{
+	"a", "an", "and", "are", "as", "at", "be", "but", "by", "for", "from",
+	"has", "have", "he", "her", "his", "i", "if", "in", "is", "it", "its",
+	"not", "of", "on", "or", "she", "so", "that", "the", "their", "them",
+	"then", "there", "they", "this", "to", "was", "we", "were", "which",
+	"will", "with", "you", "your",
+}
+
+// WordCount is the number of occurrences of a word.
+type WordCount struct {
+	Word  string `json:"word"`
+	Count int    `json:"count"`
+}
+
+// countWords return the frequency of the words used in the readable text
+// pages located under dir in s, most frequent first, see walkFiles.
+func countWords(ctx context.Context, s Store, dir string, stop map[string]bool, readable func(p string) bool, workers int) ([]WordCount, error) {
+	counts := make(map[string]int)
+	err := walkPages(ctx, s, dir, readable, workers, func(path string, body []byte) error {
+		if !isText(body) {
+			return nil
+		}
+		for _, word := range tokenize(body) {
+			if !stop[word] {
+				counts[word]++
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	words := make([]WordCount, 0, len(counts))
+	for word, n := range counts {
+		words = append(words, WordCount{Word: word, Count: n})
+	}
+	sort.Slice(words, func(i, j int) bool {
+		if words[i].Count != words[j].Count {
+			return words[i].Count > words[j].Count
+		}
+		return words[i].Word < words[j].Word
+	})
+	return words, nil
+}
+
+// MakeWordsHandler return an handler listing, as JSON, the most frequent words
+// of the text pages located under the requested folder. The 'n' query value
+// sets the number of words returned, 50 by default. Stop words are ignored
+// and the frequencies are cached per folder, and user, for ttl.
+func MakeWordsHandler(s Store, stopWords []string, ttl time.Duration, workers int) ContextHandlerFunc {
+	stop := make(map[string]bool, len(stopWords))
+	for _, w := range stopWords {
+		stop[w] = true
+	}
+	cache := newTTLCache(ttl)
+	return func(ctx context.Context, w http.ResponseWriter, r *http.Request) (int, error) {
+		valid, _ := ValidURLFromCtx(ctx)
+		v, err := cache.get(userCacheKey(ctx, valid.Dir), func() (interface{}, error) {
+			return countWords(ctx, s, valid.Dir, stop, readFilter(ctx), workers)
+		})
+		if err != nil {
+			return 0, err
+		}
+		words := v.([]WordCount)
+		n, err := strconv.Atoi(r.URL.Query().Get("n"))
+		if err != nil || n <= 0 {
+			n = 50
+		}
+		if n < len(words) {
+			words = words[:n]
+		}
+		return writeJSON(w, http.StatusOK, words)
+	}
+}